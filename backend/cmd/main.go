@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"studypartner/config"
 	"studypartner/db"
+	"studypartner/queue"
 	"studypartner/routes"
+	"studypartner/routes/study"
+	"studypartner/services"
 
 	_ "studypartner/docs" // This will be generated by swag
 
@@ -35,10 +44,31 @@ import (
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	// Stop on Ctrl-C or when the platform asks the server to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Load configuration
 	cfg := config.Load()
 
+	// Configure AI provider
+	provider, err := services.NewProvider(cfg)
+	if err != nil {
+		log.Fatal("Failed to configure AI provider:", err)
+	}
+	services.SetProvider(provider)
+	log.Printf("Using AI provider: %s", provider.Name())
+	services.SetGenerationTimeout(time.Duration(cfg.AITimeoutSeconds) * time.Second)
+	services.SetSummarizationOptions(services.SummarizationOptions{
+		ChunkTokens:   cfg.SummaryChunkTokens,
+		OverlapTokens: cfg.SummaryOverlapTokens,
+		ContextTokens: cfg.SummaryContextTokens,
+	})
+
 	// Initialize database
 	database, err := db.Initialize(cfg.DatabaseURL)
 	if err != nil {
@@ -51,6 +81,10 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// Start background generation workers, with their handlers in place
+	study.RegisterJobs()
+	waitForWorkers := queue.Start(ctx, database, cfg.JobWorkers)
+
 	// Seed test data
 	if err := db.SeedTestData(database); err != nil {
 		log.Printf("Warning: Failed to seed test data: %v", err)
@@ -93,8 +127,24 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to finish requests in flight: %v", err)
 	}
+
+	// Workers put their jobs back in the queue when ctx is cancelled
+	waitForWorkers()
 }
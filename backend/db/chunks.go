@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// ChunkMatch is a note chunk scored against a query
+type ChunkMatch struct {
+	NoteChunk
+	NoteTitle  string  `json:"note_title"`
+	Similarity float64 `json:"similarity"`
+}
+
+// VectorAvailable reports whether the pgvector extension is installed
+func VectorAvailable(database *sql.DB) bool {
+	var vectorAvailable bool
+	err := database.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = 'vector')").Scan(&vectorAvailable)
+	if err != nil {
+		return false
+	}
+	return vectorAvailable
+}
+
+// SearchChunks returns the user's note chunks most relevant to a query,
+// limited to one note when noteID is non-zero. Chunks are ranked by
+// embedding distance when embedding is given, otherwise by full-text match
+// on query.
+func SearchChunks(ctx context.Context, database *sql.DB, userID, noteID int, query string, embedding *pgvector.Vector, limit int) ([]ChunkMatch, error) {
+	var rows *sql.Rows
+	var err error
+	if embedding != nil {
+		rows, err = database.QueryContext(ctx,
+			`SELECT c.id, c.note_id, c.chunk_index, c.content, c.start_offset, c.end_offset, c.created_at,
+			        n.title, 1 - (c.embedding <=> $1) AS similarity
+			 FROM note_chunks c
+			 JOIN notes n ON n.id = c.note_id
+			 WHERE n.user_id = $2 AND ($3 = 0 OR n.id = $3) AND c.embedding IS NOT NULL
+			 ORDER BY c.embedding <=> $1
+			 LIMIT $4`,
+			*embedding, userID, noteID, limit,
+		)
+	} else {
+		tsQuery := orTSQuery(query)
+		if tsQuery == "" {
+			return nil, nil
+		}
+		rows, err = database.QueryContext(ctx,
+			`SELECT c.id, c.note_id, c.chunk_index, c.content, c.start_offset, c.end_offset, c.created_at,
+			        n.title, ts_rank(to_tsvector('english', c.content), to_tsquery('english', $1)) AS similarity
+			 FROM note_chunks c
+			 JOIN notes n ON n.id = c.note_id
+			 WHERE n.user_id = $2 AND ($3 = 0 OR n.id = $3)
+			   AND to_tsvector('english', c.content) @@ to_tsquery('english', $1)
+			 ORDER BY similarity DESC, c.note_id, c.chunk_index
+			 LIMIT $4`,
+			tsQuery, userID, noteID, limit,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []ChunkMatch
+	for rows.Next() {
+		var match ChunkMatch
+		err := rows.Scan(&match.ID, &match.NoteID, &match.ChunkIndex, &match.Content, &match.StartOffset, &match.EndOffset, &match.CreatedAt,
+			&match.NoteTitle, &match.Similarity)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// orTSQuery turns free text into a tsquery matching any of its words, so
+// questions don't need every word to appear in a chunk
+func orTSQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " | ")
+}
@@ -46,15 +46,27 @@ func RunMigrations(db *sql.DB) error {
 		createFlashcardsTable,
 		createQuizzesTable,
 		createStudySessionsTable,
+		createConversationsTable,
+		createConversationMessagesTable,
+		createJobsTable,
+		createFlashcardReviewsTable,
+		createNoteTagsTable,
+		createQuizAttemptAnswersTable,
+		createTermsTable,
+		createConceptsTable,
+		createNoteConceptsTable,
+		createConceptRelationsTable,
+		createStudyPlansTable,
+		createStudyPlanTasksTable,
 	}
 
 	// Add notes table with or without vector support
 	if vectorAvailable {
 		migrations = append([]string{createNotesTableWithVector}, migrations...)
-		migrations = append(migrations, createIndexesWithVector)
+		migrations = append(migrations, createNoteChunksTableWithVector, createIndexesWithVector)
 	} else {
 		migrations = append([]string{createNotesTableWithoutVector}, migrations...)
-		migrations = append(migrations, createIndexesWithoutVector)
+		migrations = append(migrations, createNoteChunksTableWithoutVector, createIndexesWithoutVector)
 	}
 
 	for _, migration := range migrations {
@@ -74,13 +86,38 @@ func RunMigrations(db *sql.DB) error {
 
 // runAdditionalMigrations handles migrations for existing tables
 func runAdditionalMigrations(db *sql.DB) error {
-	// Check if summaries table exists and add UNIQUE constraint if missing
+	// Columns added to existing tables
+	for _, column := range addedColumns {
+		if _, err := db.Exec(column); err != nil {
+			return fmt.Errorf("failed to add column: %w", err)
+		}
+	}
+
+	// Answers used to be deleted along with their quiz; now they outlive it
+	var cascades bool
+	err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'quiz_attempt_answers_quiz_id_fkey' AND confdeltype = 'c')",
+	).Scan(&cascades)
+	if err != nil {
+		log.Printf("Warning: Could not check quiz_attempt_answers constraint: %v", err)
+	} else if cascades {
+		_, err = db.Exec(`
+			ALTER TABLE quiz_attempt_answers
+			DROP CONSTRAINT quiz_attempt_answers_quiz_id_fkey,
+			ADD CONSTRAINT quiz_attempt_answers_quiz_id_fkey FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE SET NULL
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to keep quiz answers when quizzes are deleted: %w", err)
+		}
+	}
+
+	// Summaries used to be unique per note; now each note keeps one per style
 	var constraintExists bool
-	err := db.QueryRow(`
+	err = db.QueryRow(`
 		SELECT EXISTS (
-			SELECT 1 FROM information_schema.table_constraints 
-			WHERE table_name = 'summaries' 
-			AND constraint_name = 'summaries_note_id_unique'
+			SELECT 1 FROM information_schema.table_constraints
+			WHERE table_name = 'summaries'
+			AND constraint_name = 'summaries_note_id_style_unique'
 		)
 	`).Scan(&constraintExists)
 
@@ -90,33 +127,71 @@ func runAdditionalMigrations(db *sql.DB) error {
 	}
 
 	if !constraintExists {
-		log.Println("Adding UNIQUE constraint to summaries.note_id...")
-		
+		log.Println("Adding UNIQUE constraint to summaries (note_id, style)...")
+
 		// Remove any duplicate entries first
 		_, err = db.Exec(`
-			DELETE FROM summaries 
+			DELETE FROM summaries
 			WHERE id NOT IN (
-				SELECT MAX(id) 
-				FROM summaries 
-				GROUP BY note_id
+				SELECT MAX(id)
+				FROM summaries
+				GROUP BY note_id, style
 			)
 		`)
 		if err != nil {
 			log.Printf("Warning: Could not remove duplicate summaries: %v", err)
 		}
 
+		// Drop the old per-note constraints, from the table definition and
+		// from earlier migrations
+		for _, name := range []string{"summaries_note_id_key", "summaries_note_id_unique"} {
+			if _, err := db.Exec("ALTER TABLE summaries DROP CONSTRAINT IF EXISTS " + name); err != nil {
+				log.Printf("Warning: Could not drop constraint %s from summaries: %v", name, err)
+			}
+		}
+
 		// Add UNIQUE constraint
-		_, err = db.Exec("ALTER TABLE summaries ADD CONSTRAINT summaries_note_id_unique UNIQUE (note_id)")
+		_, err = db.Exec("ALTER TABLE summaries ADD CONSTRAINT summaries_note_id_style_unique UNIQUE (note_id, style)")
 		if err != nil {
 			log.Printf("Warning: Could not add UNIQUE constraint to summaries: %v", err)
 		} else {
-			log.Println("Successfully added UNIQUE constraint to summaries.note_id")
+			log.Println("Successfully added UNIQUE constraint to summaries (note_id, style)")
 		}
 	}
 
 	return nil
 }
 
+// addedColumns bring tables created by earlier versions up to date
+var addedColumns = []string{
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS type VARCHAR(50) NOT NULL DEFAULT 'multiple_choice'",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS answers INTEGER[]",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS answer_text TEXT",
+	"ALTER TABLE quiz_attempt_answers ALTER COLUMN selected DROP NOT NULL",
+	"ALTER TABLE quiz_attempt_answers ADD COLUMN IF NOT EXISTS selections INTEGER[]",
+	"ALTER TABLE quiz_attempt_answers ADD COLUMN IF NOT EXISTS text_answer TEXT",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS rubric TEXT[]",
+	"ALTER TABLE quiz_attempt_answers ADD COLUMN IF NOT EXISTS score INTEGER",
+	"ALTER TABLE quiz_attempt_answers ADD COLUMN IF NOT EXISTS feedback TEXT",
+	"ALTER TABLE quiz_attempt_answers ADD COLUMN IF NOT EXISTS missing_points TEXT[]",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS shuffle_seed BIGINT",
+	"ALTER TABLE summaries ADD COLUMN IF NOT EXISTS style VARCHAR(50) NOT NULL DEFAULT 'standard'",
+	"ALTER TABLE summaries ADD COLUMN IF NOT EXISTS length VARCHAR(20) NOT NULL DEFAULT 'medium'",
+	"ALTER TABLE flashcards ADD COLUMN IF NOT EXISTS explanation TEXT",
+	"ALTER TABLE flashcards ADD COLUMN IF NOT EXISTS source_start INTEGER",
+	"ALTER TABLE flashcards ADD COLUMN IF NOT EXISTS source_end INTEGER",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS explanation TEXT",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS source_start INTEGER",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS source_end INTEGER",
+	"ALTER TABLE study_sessions ADD COLUMN IF NOT EXISTS quiz_ids INTEGER[]",
+	"ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE quiz_attempt_answers ADD COLUMN IF NOT EXISTS question_snapshot JSONB",
+	// note_chunks is created after flashcards and quizzes, so the columns
+	// referencing it are only ever added here
+	"ALTER TABLE flashcards ADD COLUMN IF NOT EXISTS source_chunk_id INTEGER REFERENCES note_chunks(id) ON DELETE SET NULL",
+	"ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS source_chunk_id INTEGER REFERENCES note_chunks(id) ON DELETE SET NULL",
+}
+
 // SeedTestData creates a test user for demo purposes
 func SeedTestData(db *sql.DB) error {
 	// Check if test user already exists
@@ -191,10 +266,13 @@ CREATE TABLE IF NOT EXISTS notes (
 const createSummariesTable = `
 CREATE TABLE IF NOT EXISTS summaries (
     id SERIAL PRIMARY KEY,
-    note_id INTEGER UNIQUE REFERENCES notes(id) ON DELETE CASCADE,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    style VARCHAR(50) NOT NULL DEFAULT 'standard',
+    length VARCHAR(20) NOT NULL DEFAULT 'medium',
     content TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT summaries_note_id_style_unique UNIQUE (note_id, style)
 );`
 
 const createFlashcardsTable = `
@@ -203,6 +281,9 @@ CREATE TABLE IF NOT EXISTS flashcards (
     note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
     question TEXT NOT NULL,
     answer TEXT NOT NULL,
+    explanation TEXT,
+    source_start INTEGER, -- Byte offsets into notes.content of the passage the card came from
+    source_end INTEGER,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );`
 
@@ -210,9 +291,17 @@ const createQuizzesTable = `
 CREATE TABLE IF NOT EXISTS quizzes (
     id SERIAL PRIMARY KEY,
     note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL DEFAULT 'multiple_choice',
     question TEXT NOT NULL,
-    options TEXT[] NOT NULL,
+    options TEXT[] NOT NULL, -- Empty for cloze and short answer questions
     answer INTEGER NOT NULL,
+    answers INTEGER[], -- Every correct option of a multi-select question
+    answer_text TEXT, -- Expected or model answer of a cloze, short answer or essay question
+    rubric TEXT[], -- Key points a written answer is marked against
+    shuffle_seed BIGINT, -- Seed the options were shuffled with
+    explanation TEXT,
+    source_start INTEGER, -- Byte offsets into notes.content of the passage the question came from
+    source_end INTEGER,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );`
 
@@ -224,26 +313,225 @@ CREATE TABLE IF NOT EXISTS study_sessions (
     type VARCHAR(50) NOT NULL,
     score INTEGER,
     completed BOOLEAN DEFAULT FALSE,
+    quiz_ids INTEGER[], -- Questions of an adaptive quiz, which has no note
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`
+
+const createConversationsTable = `
+CREATE TABLE IF NOT EXISTS conversations (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    mode VARCHAR(50) NOT NULL, -- "explain", "socratic", "quiz"
+    title VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`
+
+const createConversationMessagesTable = `
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    id SERIAL PRIMARY KEY,
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL, -- "user" or "assistant"
+    content TEXT NOT NULL,
+    citations JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`
+
+const createJobsTable = `
+CREATE TABLE IF NOT EXISTS jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL, -- "summary", "flashcards", "quiz"
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- "queued", "running", "succeeded", "failed"
+    progress INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0, -- Times a worker has claimed the job
+    payload JSONB,
+    result JSONB,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`
+
+const createFlashcardReviewsTable = `
+CREATE TABLE IF NOT EXISTS flashcard_reviews (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    flashcard_id INTEGER REFERENCES flashcards(id) ON DELETE CASCADE,
+    ease DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+    interval_days INTEGER NOT NULL DEFAULT 0,
+    repetitions INTEGER NOT NULL DEFAULT 0,
+    lapses INTEGER NOT NULL DEFAULT 0,
+    due_at TIMESTAMP NOT NULL, -- UTC
+    last_reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, flashcard_id)
+);`
+
+const createNoteTagsTable = `
+CREATE TABLE IF NOT EXISTS note_tags (
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    tag VARCHAR(100) NOT NULL, -- Lowercase
+    PRIMARY KEY (note_id, tag)
+);`
+
+const createQuizAttemptAnswersTable = `
+CREATE TABLE IF NOT EXISTS quiz_attempt_answers (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER REFERENCES study_sessions(id) ON DELETE CASCADE,
+    quiz_id INTEGER REFERENCES quizzes(id) ON DELETE SET NULL, -- Null once the quiz is regenerated
+    question_snapshot JSONB, -- The question as answered, kept when the quiz is regenerated
+    selected INTEGER, -- Index of the chosen option
+    selections INTEGER[], -- Chosen options of a multi-select question
+    text_answer TEXT, -- Written answer of a cloze, short answer or essay question
+    correct BOOLEAN NOT NULL,
+    score INTEGER, -- 0-100 mark of a short answer or essay
+    feedback TEXT,
+    missing_points TEXT[],
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, quiz_id)
+);`
+
+const createTermsTable = `
+CREATE TABLE IF NOT EXISTS terms (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    term VARCHAR(255) NOT NULL,
+    definition TEXT NOT NULL,
+    source_start INTEGER, -- Byte offsets of the defining passage in notes.content
+    source_end INTEGER,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );`
 
+// Concepts are shared by all of a user's notes, so the graphs of different
+// notes join up where they mention the same concept
+const createConceptsTable = `
+CREATE TABLE IF NOT EXISTS concepts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL, -- Lowercased name the concept is matched by
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, key)
+);`
+
+const createNoteConceptsTable = `
+CREATE TABLE IF NOT EXISTS note_concepts (
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    concept_id INTEGER REFERENCES concepts(id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, concept_id)
+);`
+
+const createConceptRelationsTable = `
+CREATE TABLE IF NOT EXISTS concept_relations (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE, -- Note the relation was found in
+    source_id INTEGER REFERENCES concepts(id) ON DELETE CASCADE,
+    target_id INTEGER REFERENCES concepts(id) ON DELETE CASCADE,
+    relation VARCHAR(50) NOT NULL, -- "is_a", "part_of", "causes" or "prerequisite"
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (note_id, source_id, target_id, relation)
+);`
+
+const createStudyPlansTable = `
+CREATE TABLE IF NOT EXISTS study_plans (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    exam_date DATE NOT NULL,
+    note_ids INTEGER[] NOT NULL, -- Notes the plan covers
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`
+
+const createStudyPlanTasksTable = `
+CREATE TABLE IF NOT EXISTS study_plan_tasks (
+    id SERIAL PRIMARY KEY,
+    plan_id INTEGER REFERENCES study_plans(id) ON DELETE CASCADE,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    type VARCHAR(50) NOT NULL, -- "read", "flashcards" or "quiz"
+    minutes INTEGER NOT NULL,
+    completed BOOLEAN DEFAULT FALSE,
+    session_id INTEGER REFERENCES study_sessions(id) ON DELETE SET NULL, -- Session that completed the task
+    completed_at TIMESTAMP
+);`
+
+const createNoteChunksTableWithVector = `
+CREATE TABLE IF NOT EXISTS note_chunks (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    start_offset INTEGER NOT NULL, -- Byte offsets into notes.content
+    end_offset INTEGER NOT NULL,
+    embedding vector(384),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (note_id, chunk_index)
+);`
+
+const createNoteChunksTableWithoutVector = `
+CREATE TABLE IF NOT EXISTS note_chunks (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    start_offset INTEGER NOT NULL, -- Byte offsets into notes.content
+    end_offset INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (note_id, chunk_index)
+);`
+
 const createIndexesWithVector = `
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_notes_user_id ON notes(user_id);
 CREATE INDEX IF NOT EXISTS idx_notes_embedding ON notes USING ivfflat (embedding vector_cosine_ops);
+CREATE INDEX IF NOT EXISTS idx_note_chunks_note_id ON note_chunks(note_id);
+CREATE INDEX IF NOT EXISTS idx_note_chunks_embedding ON note_chunks USING ivfflat (embedding vector_cosine_ops);
 CREATE INDEX IF NOT EXISTS idx_summaries_note_id ON summaries(note_id);
 CREATE INDEX IF NOT EXISTS idx_flashcards_note_id ON flashcards(note_id);
 CREATE INDEX IF NOT EXISTS idx_quizzes_note_id ON quizzes(note_id);
 CREATE INDEX IF NOT EXISTS idx_study_sessions_user_id ON study_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_study_sessions_note_id ON study_sessions(note_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations(user_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_note_id ON conversations(note_id);
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation_id ON conversation_messages(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_queued ON jobs(created_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_user_due ON flashcard_reviews(user_id, due_at);
+CREATE INDEX IF NOT EXISTS idx_note_tags_tag ON note_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_quiz_attempt_answers_session_id ON quiz_attempt_answers(session_id);
+CREATE INDEX IF NOT EXISTS idx_quiz_attempt_answers_quiz_id ON quiz_attempt_answers(quiz_id);
+CREATE INDEX IF NOT EXISTS idx_terms_note_id ON terms(note_id);
+CREATE INDEX IF NOT EXISTS idx_note_concepts_concept_id ON note_concepts(concept_id);
+CREATE INDEX IF NOT EXISTS idx_study_plans_user_id ON study_plans(user_id);
+CREATE INDEX IF NOT EXISTS idx_study_plan_tasks_plan_day ON study_plan_tasks(plan_id, day);
 `
 
 const createIndexesWithoutVector = `
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_notes_user_id ON notes(user_id);
+CREATE INDEX IF NOT EXISTS idx_note_chunks_note_id ON note_chunks(note_id);
 CREATE INDEX IF NOT EXISTS idx_summaries_note_id ON summaries(note_id);
 CREATE INDEX IF NOT EXISTS idx_flashcards_note_id ON flashcards(note_id);
 CREATE INDEX IF NOT EXISTS idx_quizzes_note_id ON quizzes(note_id);
 CREATE INDEX IF NOT EXISTS idx_study_sessions_user_id ON study_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_study_sessions_note_id ON study_sessions(note_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations(user_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_note_id ON conversations(note_id);
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation_id ON conversation_messages(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_queued ON jobs(created_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_user_due ON flashcard_reviews(user_id, due_at);
+CREATE INDEX IF NOT EXISTS idx_note_tags_tag ON note_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_quiz_attempt_answers_session_id ON quiz_attempt_answers(session_id);
+CREATE INDEX IF NOT EXISTS idx_quiz_attempt_answers_quiz_id ON quiz_attempt_answers(quiz_id);
+CREATE INDEX IF NOT EXISTS idx_terms_note_id ON terms(note_id);
+CREATE INDEX IF NOT EXISTS idx_note_concepts_concept_id ON note_concepts(concept_id);
+CREATE INDEX IF NOT EXISTS idx_study_plans_user_id ON study_plans(user_id);
+CREATE INDEX IF NOT EXISTS idx_study_plan_tasks_plan_day ON study_plan_tasks(plan_id, day);
 `
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/pgvector/pgvector-go"
@@ -24,41 +25,200 @@ type Note struct {
 	FileName    string    `json:"file_name" db:"file_name"`
 	FileSize    int64     `json:"file_size" db:"file_size"`
 	Embedding   pgvector.Vector `json:"-" db:"embedding"` // Hidden from JSON
+	Tags        []string  `json:"tags,omitempty"` // From note_tags
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// NoteChunk is a passage of a note with its own embedding, so search can
+// match text anywhere in the note
+type NoteChunk struct {
+	ID          int             `json:"id" db:"id"`
+	NoteID      int             `json:"note_id" db:"note_id"`
+	ChunkIndex  int             `json:"chunk_index" db:"chunk_index"`
+	Content     string          `json:"content" db:"content"`
+	StartOffset int             `json:"start_offset" db:"start_offset"` // Byte offset into Note.Content
+	EndOffset   int             `json:"end_offset" db:"end_offset"`
+	Embedding   pgvector.Vector `json:"-" db:"embedding"` // Hidden from JSON
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
 type Summary struct {
 	ID        int       `json:"id" db:"id"`
 	NoteID    int       `json:"note_id" db:"note_id"`
+	Style     string    `json:"style" db:"style"`
+	Length    string    `json:"length" db:"length"`
 	Content   string    `json:"content" db:"content"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type Flashcard struct {
-	ID        int       `json:"id" db:"id"`
-	NoteID    int       `json:"note_id" db:"note_id"`
-	Question  string    `json:"question" db:"question"`
-	Answer    string    `json:"answer" db:"answer"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID            int       `json:"id" db:"id"`
+	NoteID        int       `json:"note_id" db:"note_id"`
+	Question      string    `json:"question" db:"question"`
+	Answer        string    `json:"answer" db:"answer"`
+	Explanation   string    `json:"explanation,omitempty" db:"explanation"`
+	SourceChunkID *int      `json:"source_chunk_id,omitempty" db:"source_chunk_id"` // Chunk holding the source passage
+	SourceStart   *int      `json:"source_start,omitempty" db:"source_start"`       // Byte offsets of the source passage in the note
+	SourceEnd     *int      `json:"source_end,omitempty" db:"source_end"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// FlashcardReview is one user's spaced repetition schedule for a flashcard
+type FlashcardReview struct {
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	FlashcardID    int        `json:"flashcard_id" db:"flashcard_id"`
+	Ease           float64    `json:"ease" db:"ease"`
+	IntervalDays   int        `json:"interval_days" db:"interval_days"`
+	Repetitions    int        `json:"repetitions" db:"repetitions"`
+	Lapses         int        `json:"lapses" db:"lapses"`
+	DueAt          time.Time  `json:"due_at" db:"due_at"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at,omitempty" db:"last_reviewed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type Quiz struct {
-	ID        int       `json:"id" db:"id"`
-	NoteID    int       `json:"note_id" db:"note_id"`
-	Question  string    `json:"question" db:"question"`
-	Options   []string  `json:"options" db:"options"`
-	Answer    int       `json:"answer" db:"answer"` // Index of correct option
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID            int       `json:"id" db:"id"`
+	NoteID        int       `json:"note_id" db:"note_id"`
+	Type          string    `json:"type" db:"type"` // "multiple_choice", "true_false", "multi_select", "cloze", "short_answer" or "essay"
+	Question      string    `json:"question" db:"question"`
+	Options       []string  `json:"options" db:"options"`
+	Answer        int       `json:"answer" db:"answer"`                       // Index of correct option
+	Answers       []int64   `json:"answers,omitempty" db:"answers"`           // Every correct option, for multi-select
+	AnswerText    string    `json:"answer_text,omitempty" db:"answer_text"`   // Expected or model answer, for cloze, short answer and essay
+	Rubric        []string  `json:"rubric,omitempty" db:"rubric"`             // Key points a written answer is marked against
+	ShuffleSeed   *int64    `json:"shuffle_seed,omitempty" db:"shuffle_seed"` // Seed the options were shuffled with
+	Explanation   string    `json:"explanation,omitempty" db:"explanation"`
+	SourceChunkID *int      `json:"source_chunk_id,omitempty" db:"source_chunk_id"` // Chunk holding the source passage
+	SourceStart   *int      `json:"source_start,omitempty" db:"source_start"`       // Byte offsets of the source passage in the note
+	SourceEnd     *int      `json:"source_end,omitempty" db:"source_end"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// Term is a key term of a note with its definition
+type Term struct {
+	ID          int       `json:"id" db:"id"`
+	NoteID      int       `json:"note_id" db:"note_id"`
+	Term        string    `json:"term" db:"term"`
+	Definition  string    `json:"definition" db:"definition"`
+	SourceStart *int      `json:"source_start,omitempty" db:"source_start"` // Byte offsets of the defining passage in the note
+	SourceEnd   *int      `json:"source_end,omitempty" db:"source_end"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Concept is a concept from one or more of a user's notes
+type Concept struct {
+	ID          int     `json:"id" db:"id"`
+	Name        string  `json:"name" db:"name"`
+	Description string  `json:"description,omitempty" db:"description"`
+	NoteIDs     []int64 `json:"note_ids" db:"-"` // Notes the concept appears in
+}
+
+// ConceptRelation is a relation between two concepts, read from source to
+// target
+type ConceptRelation struct {
+	SourceID int     `json:"source_id" db:"source_id"`
+	TargetID int     `json:"target_id" db:"target_id"`
+	Relation string  `json:"relation" db:"relation"` // "is_a", "part_of", "causes" or "prerequisite"
+	NoteIDs  []int64 `json:"note_ids" db:"-"`        // Notes the relation was found in
+}
+
+// StudyPlan is a day-by-day schedule of work on a set of notes leading up
+// to an exam
+type StudyPlan struct {
+	ID        int             `json:"id" db:"id"`
+	UserID    int             `json:"user_id" db:"user_id"`
+	ExamDate  time.Time       `json:"exam_date" db:"exam_date"`
+	NoteIDs   []int64         `json:"note_ids" db:"note_ids"`
+	Tasks     []StudyPlanTask `json:"tasks,omitempty" db:"-"`
+	Behind    int             `json:"behind" db:"-"` // Incomplete tasks from days already past
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// StudyPlanTask is one piece of work on a day of a study plan
+type StudyPlanTask struct {
+	ID          int        `json:"id" db:"id"`
+	PlanID      int        `json:"plan_id" db:"plan_id"`
+	NoteID      int        `json:"note_id" db:"note_id"`
+	Day         time.Time  `json:"day" db:"day"`
+	Type        string     `json:"type" db:"type"` // "read", "flashcards" or "quiz"
+	Minutes     int        `json:"minutes" db:"minutes"`
+	Completed   bool       `json:"completed" db:"completed"`
+	SessionID   *int       `json:"session_id,omitempty" db:"session_id"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
 }
 
 type StudySession struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
 	NoteID    int       `json:"note_id" db:"note_id"`
-	Type      string    `json:"type" db:"type"` // "flashcard", "quiz", "summary"
+	Type      string    `json:"type" db:"type"` // "flashcard", "quiz", "summary", "adaptive_quiz"
 	Score     *int      `json:"score,omitempty" db:"score"`
 	Completed bool      `json:"completed" db:"completed"`
+	QuizIDs   []int64   `json:"quiz_ids,omitempty" db:"quiz_ids"` // Questions of a quiz session, as it started
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
+
+// QuizAttemptAnswer is the option chosen for one question in a quiz session,
+// graded by the server
+type QuizAttemptAnswer struct {
+	ID            int       `json:"id" db:"id"`
+	SessionID     int       `json:"session_id" db:"session_id"`
+	QuizID        int       `json:"quiz_id" db:"quiz_id"` // 0 once the quiz is regenerated
+	Question      string    `json:"question" db:"-"`
+	Selected      *int      `json:"selected,omitempty" db:"selected"`
+	Selections    []int64   `json:"selections,omitempty" db:"selections"`
+	TextAnswer    string    `json:"text_answer,omitempty" db:"text_answer"`
+	Correct       bool      `json:"correct" db:"correct"`
+	Score         *int      `json:"score,omitempty" db:"score"` // 0-100 mark of a short answer or essay
+	Feedback      string    `json:"feedback,omitempty" db:"feedback"`
+	MissingPoints []string  `json:"missing_points,omitempty" db:"missing_points"` // Key points the answer missed
+	Answer        int       `json:"answer" db:"-"`                                // Index of the correct option
+	Answers       []int64   `json:"answers,omitempty" db:"-"`                     // Every correct option, for multi-select
+	AnswerText    string    `json:"answer_text,omitempty" db:"-"`                 // Expected or model answer, for written questions
+	Explanation   string    `json:"explanation,omitempty" db:"-"`                 // Why the correct option is right
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// Conversation is a multi-turn tutor session about a note
+type Conversation struct {
+	ID        int                   `json:"id" db:"id"`
+	UserID    int                   `json:"user_id" db:"user_id"`
+	NoteID    int                   `json:"note_id" db:"note_id"`
+	Mode      string                `json:"mode" db:"mode"` // "explain", "socratic", "quiz"
+	Title     string                `json:"title" db:"title"`
+	Messages  []ConversationMessage `json:"messages,omitempty"`
+	CreatedAt time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+type ConversationMessage struct {
+	ID             int             `json:"id" db:"id"`
+	ConversationID int             `json:"conversation_id" db:"conversation_id"`
+	Role           string          `json:"role" db:"role"` // "user" or "assistant"
+	Content        string          `json:"content" db:"content"`
+	Citations      json.RawMessage `json:"citations,omitempty" db:"citations"` // Passages the reply is grounded in
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Job is a background generation task. Result holds the handler's output
+// once the job has succeeded.
+type Job struct {
+	ID         int             `json:"id" db:"id"`
+	UserID     int             `json:"user_id" db:"user_id"`
+	NoteID     int             `json:"note_id" db:"note_id"`
+	Type       string          `json:"type" db:"type"`         // "summary", "flashcards", "quiz", "glossary" or "concepts"
+	Status     string          `json:"status" db:"status"`     // "queued", "running", "succeeded", "failed"
+	Progress   int             `json:"progress" db:"progress"` // Percent complete
+	Attempts   int             `json:"attempts" db:"attempts"` // Times a worker has claimed the job
+	Payload    json.RawMessage `json:"payload,omitempty" db:"payload"`
+	Result     json.RawMessage `json:"result,omitempty" db:"result"`
+	Error      string          `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+}
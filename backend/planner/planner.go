@@ -0,0 +1,195 @@
+// Package planner lays out a day-by-day study plan for an exam: reading each
+// note, reviewing its flashcards and taking its quiz, spread so that every
+// day has a similar amount of work. Like srs it has no dependencies, so
+// plans can be worked out and tuned without a database.
+package planner
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Task types
+const (
+	TaskRead       = "read"
+	TaskFlashcards = "flashcards"
+	TaskQuiz       = "quiz"
+)
+
+// Planning parameters
+const (
+	// MaxDays is the furthest ahead an exam can be planned for
+	MaxDays = 365
+
+	// WeakScore is the quiz score below which a note gets an extra
+	// flashcard review and quiz
+	WeakScore = 70
+
+	flashcardPasses    = 2   // Flashcard reviews per note: after reading and before the exam
+	quizzes            = 1   // Quizzes per note
+	wordsPerMinute     = 200 // Reading speed
+	secondsPerCard     = 30
+	secondsPerQuestion = 90
+)
+
+// ErrExamPassed is returned when there are no days left before the exam
+var ErrExamPassed = errors.New("exam date must be after today")
+
+// ErrTooFar is returned for exams more than MaxDays away
+var ErrTooFar = errors.New("exam date is too far away")
+
+// Note is the work on one note, and how much of it is done
+type Note struct {
+	ID         int
+	Words      int  // Length of the note
+	Flashcards int  // Cards to go through in a review
+	Questions  int  // Questions in the quiz
+	QuizScore  *int // Latest quiz score, if the quiz has been taken
+
+	// Tasks already completed in the plan
+	Read           bool
+	FlashcardsDone int
+	QuizzesDone    int
+}
+
+// Task is one piece of work on a day of the plan
+type Task struct {
+	NoteID  int
+	Day     time.Time // Midnight UTC
+	Type    string    // TaskRead, TaskFlashcards or TaskQuiz
+	Minutes int
+}
+
+// Day returns midnight UTC of the day t falls on
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Schedule plans the work left on notes over the days from today up to the
+// day before the exam. Each note is read first, then its flashcard reviews
+// and quizzes alternate, a day apart where the plan is long enough and as
+// few to a day as will fit otherwise. Each task goes on the least busy day
+// it can, so the work is spread evenly; a note that scored below WeakScore
+// gets an extra review and quiz.
+func Schedule(notes []Note, today, exam time.Time) ([]Task, error) {
+	today, exam = Day(today), Day(exam)
+	days := int(exam.Sub(today).Hours() / 24)
+	if days <= 0 {
+		return nil, ErrExamPassed
+	}
+	if days > MaxDays {
+		return nil, ErrTooFar
+	}
+
+	chains := make([][]Task, len(notes))
+	longest := 0
+	for i, note := range notes {
+		chains[i] = remainingTasks(note)
+		longest = max(longest, len(chains[i]))
+	}
+
+	// Place the notes' first tasks, then their second, and so on, so no
+	// note gets the quiet days to itself. preferred spaces each note's
+	// tasks evenly over the days it has left.
+	load := make([]int, days)
+	earliest := make([]int, len(notes))
+	preferred := make([]int, len(notes))
+	var tasks []Task
+	for step := 0; step < longest; step++ {
+		for i, chain := range chains {
+			if step >= len(chain) {
+				continue
+			}
+			// Leave room for the tasks still to come on the note, a day
+			// each, or perDay to a day when the plan is too short
+			perDay := ceilDiv(len(chain), days)
+			latest := max(days-ceilDiv(len(chain)-step, perDay), earliest[i])
+			day := earliest[i]
+			for d := earliest[i] + 1; d <= latest; d++ {
+				if load[d] < load[day] || load[d] == load[day] && abs(d-preferred[i]) < abs(day-preferred[i]) {
+					day = d
+				}
+			}
+
+			task := chain[step]
+			task.Day = today.AddDate(0, 0, day)
+			load[day] += task.Minutes
+			tasks = append(tasks, task)
+
+			left := len(chain) - step - 1
+			if (days-1-day)*perDay >= left {
+				earliest[i] = min(day+1, days-1)
+			}
+			if left > 0 {
+				preferred[i] = day + max((days-1-day)/left, 1)
+			}
+		}
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Day.Before(tasks[j].Day)
+	})
+	return tasks, nil
+}
+
+// remainingTasks lists the tasks left on a note in the order to do them
+func remainingTasks(note Note) []Task {
+	flashcards, quizzesLeft := flashcardPasses, quizzes
+	if note.QuizScore != nil && *note.QuizScore < WeakScore {
+		flashcards++
+		quizzesLeft++
+	}
+	flashcards = max(flashcards-note.FlashcardsDone, 0)
+	quizzesLeft = max(quizzesLeft-note.QuizzesDone, 0)
+
+	var chain []Task
+	if !note.Read {
+		chain = append(chain, Task{NoteID: note.ID, Type: TaskRead, Minutes: readMinutes(note)})
+	}
+	for flashcards > 0 || quizzesLeft > 0 {
+		if flashcards > 0 {
+			chain = append(chain, Task{NoteID: note.ID, Type: TaskFlashcards, Minutes: flashcardMinutes(note)})
+			flashcards--
+		}
+		if quizzesLeft > 0 {
+			chain = append(chain, Task{NoteID: note.ID, Type: TaskQuiz, Minutes: quizMinutes(note)})
+			quizzesLeft--
+		}
+	}
+	return chain
+}
+
+func readMinutes(note Note) int {
+	return clamp((note.Words+wordsPerMinute-1)/wordsPerMinute, 10, 90)
+}
+
+func flashcardMinutes(note Note) int {
+	if note.Flashcards == 0 {
+		return 10
+	}
+	return clamp((note.Flashcards*secondsPerCard+59)/60, 5, 60)
+}
+
+func quizMinutes(note Note) int {
+	if note.Questions == 0 {
+		return 15
+	}
+	return clamp((note.Questions*secondsPerQuestion+59)/60, 10, 45)
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func clamp(value, low, high int) int {
+	return min(max(value, low), high)
+}
@@ -0,0 +1,199 @@
+package planner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var today = time.Date(2026, 5, 4, 15, 30, 0, 0, time.FixedZone("EST", -5*3600))
+
+func score(s int) *int {
+	return &s
+}
+
+func taskTypes(tasks []Task, noteID int) []string {
+	var types []string
+	for _, task := range tasks {
+		if task.NoteID == noteID {
+			types = append(types, task.Type)
+		}
+	}
+	return types
+}
+
+func TestScheduleDates(t *testing.T) {
+	tests := []struct {
+		name string
+		exam time.Time
+		err  error
+	}{
+		{"exam today", today, ErrExamPassed},
+		{"exam yesterday", today.AddDate(0, 0, -1), ErrExamPassed},
+		{"exam tomorrow", today.AddDate(0, 0, 1), nil},
+		{"exam at the limit", today.AddDate(0, 0, MaxDays), nil},
+		{"exam too far", today.AddDate(0, 0, MaxDays+1), ErrTooFar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Schedule([]Note{{ID: 1, Words: 1000}}, today, tt.exam)
+			if err != tt.err {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestScheduleTasks(t *testing.T) {
+	tests := []struct {
+		name string
+		note Note
+		want []string
+	}{
+		{"new note", Note{ID: 1}, []string{TaskRead, TaskFlashcards, TaskQuiz, TaskFlashcards}},
+		{"weak score", Note{ID: 1, QuizScore: score(50)}, []string{TaskRead, TaskFlashcards, TaskQuiz, TaskFlashcards, TaskQuiz, TaskFlashcards}},
+		{"passing score", Note{ID: 1, QuizScore: score(WeakScore)}, []string{TaskRead, TaskFlashcards, TaskQuiz, TaskFlashcards}},
+		{"read", Note{ID: 1, Read: true}, []string{TaskFlashcards, TaskQuiz, TaskFlashcards}},
+		{"partly done", Note{ID: 1, Read: true, FlashcardsDone: 1, QuizzesDone: 1}, []string{TaskFlashcards}},
+		{"weak and partly done", Note{ID: 1, Read: true, FlashcardsDone: 2, QuizzesDone: 1, QuizScore: score(40)}, []string{TaskFlashcards, TaskQuiz}},
+		{"all done", Note{ID: 1, Read: true, FlashcardsDone: 2, QuizzesDone: 1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := Schedule([]Note{tt.note}, today, today.AddDate(0, 0, 14))
+			if err != nil {
+				t.Fatalf("Schedule: %v", err)
+			}
+			if got := taskTypes(tasks, 1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tasks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleDays(t *testing.T) {
+	notes := []Note{
+		{ID: 1, Words: 4000, Flashcards: 40, Questions: 10},
+		{ID: 2, Words: 1500, Flashcards: 20, Questions: 5},
+		{ID: 3, Words: 800, Flashcards: 10, Questions: 5, QuizScore: score(30)},
+	}
+	tests := []struct {
+		name string
+		days int
+	}{
+		{"one day", 1},
+		{"three days", 3},
+		{"a week", 7},
+		{"a month", 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, exam := Day(today), Day(today).AddDate(0, 0, tt.days)
+			tasks, err := Schedule(notes, today, exam)
+			if err != nil {
+				t.Fatalf("Schedule: %v", err)
+			}
+			if len(tasks) != 4+4+6 {
+				t.Fatalf("got %d tasks, want 14", len(tasks))
+			}
+
+			last := map[int]time.Time{}
+			load := map[time.Time]int{}
+			for i, task := range tasks {
+				if task.Day.Before(first) || !task.Day.Before(exam) {
+					t.Errorf("task %d on %v, outside %v to %v", i, task.Day, first, exam)
+				}
+				if i > 0 && task.Day.Before(tasks[i-1].Day) {
+					t.Errorf("task %d is out of order", i)
+				}
+				// A note's tasks go on separate days when there are enough
+				if previous, ok := last[task.NoteID]; ok {
+					needed := len(taskTypes(tasks, task.NoteID))
+					if tt.days >= needed && !task.Day.After(previous) {
+						t.Errorf("note %d has two tasks on %v", task.NoteID, task.Day)
+					} else if task.Day.Before(previous) {
+						t.Errorf("note %d's tasks are out of order", task.NoteID)
+					}
+				}
+				last[task.NoteID] = task.Day
+				load[task.Day] += task.Minutes
+			}
+
+			// The work is spread over every day, and no day carries more
+			// than its share plus the longest task
+			if len(load) != min(tt.days, len(tasks)) {
+				t.Errorf("work on %d days, want %d", len(load), min(tt.days, len(tasks)))
+			}
+			total, longest := 0, 0
+			for _, task := range tasks {
+				total += task.Minutes
+				longest = max(longest, task.Minutes)
+			}
+			for day, minutes := range load {
+				if minutes > total/tt.days+longest {
+					t.Errorf("%v has %d minutes, over a share of %d plus %d", day, minutes, total/tt.days, longest)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleShortPlan(t *testing.T) {
+	// Six tasks over three days go two to a day, in order
+	note := Note{ID: 1, QuizScore: score(20)}
+	tasks, err := Schedule([]Note{note}, today, today.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	perDay := map[time.Time]int{}
+	for _, task := range tasks {
+		perDay[task.Day]++
+	}
+	if len(tasks) != 6 || len(perDay) != 3 {
+		t.Fatalf("got %d tasks over %d days, want 6 over 3", len(tasks), len(perDay))
+	}
+	for day, count := range perDay {
+		if count != 2 {
+			t.Errorf("%v has %d tasks, want 2", day, count)
+		}
+	}
+	if got := taskTypes(tasks, 1); got[0] != TaskRead {
+		t.Errorf("tasks start with %s, want %s", got[0], TaskRead)
+	}
+}
+
+func TestScheduleStartsToday(t *testing.T) {
+	tasks, err := Schedule([]Note{{ID: 1}}, today, today.AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	if want := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC); !tasks[0].Day.Equal(want) {
+		t.Errorf("first task on %v, want %v", tasks[0].Day, want)
+	}
+}
+
+func TestTaskMinutes(t *testing.T) {
+	tests := []struct {
+		name                   string
+		note                   Note
+		read, flashcards, quiz int
+	}{
+		{"empty", Note{}, 10, 10, 15},
+		{"typical", Note{Words: 3000, Flashcards: 20, Questions: 10}, 15, 10, 15},
+		{"huge", Note{Words: 100000, Flashcards: 1000, Questions: 100}, 90, 60, 45},
+		{"tiny", Note{Words: 50, Flashcards: 1, Questions: 1}, 10, 5, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readMinutes(tt.note); got != tt.read {
+				t.Errorf("read minutes = %d, want %d", got, tt.read)
+			}
+			if got := flashcardMinutes(tt.note); got != tt.flashcards {
+				t.Errorf("flashcard minutes = %d, want %d", got, tt.flashcards)
+			}
+			if got := quizMinutes(tt.note); got != tt.quiz {
+				t.Errorf("quiz minutes = %d, want %d", got, tt.quiz)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"studypartner/db"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	// pollInterval is how often idle workers check for jobs queued by other
+	// server instances
+	pollInterval = 2 * time.Second
+
+	// heartbeatInterval is how often a running job is marked alive. Jobs
+	// that miss staleAfter worth of heartbeats belonged to a server that
+	// went away and are claimed again.
+	heartbeatInterval = 30 * time.Second
+	staleAfter        = "2 minutes"
+
+	// MaxAttempts is how many times a job is claimed before it is given up
+	// on. Only abandoned jobs are claimed again, so this limits jobs that
+	// keep taking their server down.
+	MaxAttempts = 3
+)
+
+// ErrAttemptLost is returned by Lock when the job was claimed again after
+// the attempt started
+var ErrAttemptLost = errors.New("job was claimed again by another worker")
+
+// ProgressFunc records how far a job has got, as a percentage
+type ProgressFunc func(percent int)
+
+// Handler runs one job and returns its result, which is stored as JSON
+type Handler func(ctx context.Context, database *sql.DB, job db.Job, progress ProgressFunc) (interface{}, error)
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+
+	// wake lets Enqueue start an idle worker without waiting for the poll
+	wake = make(chan struct{}, 1)
+)
+
+// Register sets the handler for a job type
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+func handlerFor(jobType string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[jobType]
+	return handler, ok
+}
+
+// Enqueue stores a new job for the workers to pick up. payload may be nil.
+func Enqueue(database *sql.DB, userID, noteID int, jobType string, payload interface{}) (db.Job, error) {
+	if _, ok := handlerFor(jobType); !ok {
+		return db.Job{}, fmt.Errorf("unknown job type %q", jobType)
+	}
+
+	var payloadJSON []byte
+	if payload != nil {
+		var err error
+		if payloadJSON, err = json.Marshal(payload); err != nil {
+			return db.Job{}, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+	}
+
+	job, err := scanJob(database.QueryRow(
+		`INSERT INTO jobs (user_id, note_id, type, status, payload) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+jobColumns,
+		userID, noteID, jobType, StatusQueued, payloadJSON,
+	))
+	if err != nil {
+		return db.Job{}, err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Lock locks a running job's row until tx ends, as long as this attempt
+// still owns the job. Handlers call it in the transaction that saves their
+// output, so an attempt that was claimed again can't overwrite the output
+// of the attempt that replaced it.
+func Lock(tx *sql.Tx, job db.Job) error {
+	var id int
+	err := tx.QueryRow(
+		"SELECT id FROM jobs WHERE id = $1 AND status = $2 AND attempts = $3 FOR UPDATE",
+		job.ID, StatusRunning, job.Attempts,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrAttemptLost
+	}
+	return err
+}
+
+// Get fetches a job owned by the user
+func Get(database *sql.DB, jobID string, userID interface{}) (db.Job, error) {
+	return scanJob(database.QueryRow(
+		"SELECT "+jobColumns+" FROM jobs WHERE id = $1 AND user_id = $2",
+		jobID, userID,
+	))
+}
+
+// Start launches the worker pool. Workers stop when ctx is cancelled,
+// putting the jobs they were running back in the queue; the returned
+// function waits for them to finish.
+func Start(ctx context.Context, database *sql.DB, workers int) (wait func()) {
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx, database)
+		}()
+	}
+	return wg.Wait
+}
+
+// work claims and runs jobs until ctx is cancelled
+func work(ctx context.Context, database *sql.DB) {
+	for {
+		job, err := claim(ctx, database)
+		if err == nil {
+			run(ctx, database, job)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+			fmt.Printf("Failed to claim job: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// claim marks the oldest queued or abandoned job as running, counting the
+// attempt. Abandoned jobs out of attempts are failed instead. SKIP LOCKED
+// lets several workers, or several servers, share the table.
+func claim(ctx context.Context, database *sql.DB) (db.Job, error) {
+	_, err := database.ExecContext(ctx,
+		`UPDATE jobs SET status = $1, error = $2, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE status = $3 AND updated_at < CURRENT_TIMESTAMP - $4::interval AND attempts >= $5`,
+		StatusFailed, fmt.Sprintf("job was abandoned %d times", MaxAttempts), StatusRunning, staleAfter, MaxAttempts,
+	)
+	if err != nil {
+		return db.Job{}, fmt.Errorf("failed to give up abandoned jobs: %w", err)
+	}
+
+	return scanJob(database.QueryRowContext(ctx,
+		`UPDATE jobs SET status = $1, progress = 0, attempts = attempts + 1, started_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = $2 OR (status = $1 AND updated_at < CURRENT_TIMESTAMP - $3::interval AND attempts < $4)
+			ORDER BY created_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+jobColumns,
+		StatusRunning, StatusQueued, staleAfter, MaxAttempts,
+	))
+}
+
+// run executes a claimed job and records its outcome. The job is the
+// worker's while the row is running on the same attempt; if the job is
+// claimed again in the meantime the handler is cancelled, Lock keeps it
+// from saving its output and its outcome is dropped, so a job is never
+// finished twice. A job stopped by the server shutting down is queued
+// again.
+func run(ctx context.Context, database *sql.DB, job db.Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go heartbeat(database, job, done, cancel)
+	result, err := execute(jobCtx, database, job)
+	close(done)
+	cancel()
+
+	if errors.Is(err, ErrAttemptLost) {
+		fmt.Printf("Job %d was claimed again before attempt %d saved its output, discarding it\n", job.ID, job.Attempts)
+		return
+	}
+	if err != nil && ctx.Err() != nil {
+		fmt.Printf("Job %d (%s) stopped by shutdown, queuing it again\n", job.ID, job.Type)
+		_, err := database.Exec(
+			`UPDATE jobs SET status = $1, progress = 0, started_at = NULL, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2 AND status = $3 AND attempts = $4`,
+			StatusQueued, job.ID, StatusRunning, job.Attempts,
+		)
+		if err != nil {
+			fmt.Printf("Failed to queue job %d again: %v\n", job.ID, err)
+		}
+		return
+	}
+
+	var resultJSON []byte
+	if err == nil {
+		resultJSON, err = json.Marshal(result)
+	}
+
+	var outcome sql.Result
+	if err != nil {
+		fmt.Printf("Job %d (%s) failed: %v\n", job.ID, job.Type, err)
+		outcome, err = database.Exec(
+			`UPDATE jobs SET status = $1, error = $2, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $3 AND status = $4 AND attempts = $5`,
+			StatusFailed, err.Error(), job.ID, StatusRunning, job.Attempts,
+		)
+	} else {
+		outcome, err = database.Exec(
+			`UPDATE jobs SET status = $1, progress = 100, result = $2, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $3 AND status = $4 AND attempts = $5`,
+			StatusSucceeded, resultJSON, job.ID, StatusRunning, job.Attempts,
+		)
+	}
+	if err != nil {
+		fmt.Printf("Failed to record outcome of job %d: %v\n", job.ID, err)
+		return
+	}
+	if rows, _ := outcome.RowsAffected(); rows == 0 {
+		fmt.Printf("Job %d was claimed again after attempt %d saved its output, leaving the outcome to the new attempt\n", job.ID, job.Attempts)
+	}
+}
+
+// heartbeat keeps a running job from being treated as abandoned, and calls
+// lost if the job turns out to have been claimed by another worker
+func heartbeat(database *sql.DB, job db.Job, done <-chan struct{}, lost func()) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			result, err := database.Exec(
+				"UPDATE jobs SET updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = $2 AND attempts = $3",
+				job.ID, StatusRunning, job.Attempts,
+			)
+			if err != nil {
+				fmt.Printf("Failed to record heartbeat for job %d: %v\n", job.ID, err)
+				continue
+			}
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				fmt.Printf("Job %d was claimed by another worker, stopping attempt %d\n", job.ID, job.Attempts)
+				lost()
+				return
+			}
+		}
+	}
+}
+
+// execute calls the job's handler, turning a panic into an error so one bad
+// job can't take a worker down
+func execute(ctx context.Context, database *sql.DB, job db.Job) (result interface{}, err error) {
+	handler, ok := handlerFor(job.Type)
+	if !ok {
+		return nil, fmt.Errorf("no handler for job type %q", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	progress := func(percent int) {
+		if percent < 0 || percent > 99 {
+			return
+		}
+		_, err := database.Exec(
+			"UPDATE jobs SET progress = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND attempts = $3 AND progress < $1",
+			percent, job.ID, job.Attempts,
+		)
+		if err != nil {
+			fmt.Printf("Failed to update progress of job %d: %v\n", job.ID, err)
+		}
+	}
+
+	return handler(ctx, database, job, progress)
+}
+
+const jobColumns = "id, user_id, note_id, type, status, progress, attempts, payload, result, COALESCE(error, ''), created_at, started_at, finished_at"
+
+func scanJob(row *sql.Row) (db.Job, error) {
+	var job db.Job
+	var payload, result []byte
+	err := row.Scan(&job.ID, &job.UserID, &job.NoteID, &job.Type, &job.Status, &job.Progress, &job.Attempts, &payload, &result, &job.Error, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	job.Payload = payload
+	job.Result = result
+	return job, err
+}
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"database/sql"
+	"net/http"
+
+	"studypartner/middleware"
+	"studypartner/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupJobsRoutes(router *gin.RouterGroup, database *sql.DB) {
+	jobs := router.Group("/jobs")
+	jobs.Use(middleware.AuthRequired())
+	{
+		jobs.GET("/:id", getJob(database))
+	}
+}
+
+// GetJob godoc
+// @Summary Get background job status
+// @Description Get the status and progress of a generation job. Once it has succeeded, result holds the generated summary, flashcards or quiz; if it failed, error says why.
+// @Tags Jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} db.Job "Job status"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Router /jobs/{id} [get]
+func getJob(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		job, err := queue.Get(database, c.Param("id"), userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
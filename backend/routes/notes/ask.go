@@ -0,0 +1,183 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"studypartner/db"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pgvector/pgvector-go"
+)
+
+const (
+	defaultAskPassages = 5
+	maxAskPassages     = 10
+)
+
+type AskRequest struct {
+	Question string `json:"question" binding:"required"`
+	Limit    int    `json:"limit"` // Number of passages to retrieve, default 5
+}
+
+// AskNote godoc
+// @Summary Ask a question about a note
+// @Description Answer a question from the most relevant passages of one note, with citations
+// @Tags Notes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param request body AskRequest true "Question"
+// @Success 200 {object} services.Answer "Answer with cited passages"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /notes/{id}/ask [post]
+func askNote(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req AskRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		noteID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		// Check if note belongs to user
+		var exists bool
+		err = database.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM notes WHERE id = $1 AND user_id = $2)",
+			noteID, userID,
+		).Scan(&exists)
+		if err != nil || !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		answerQuestion(c, database, userID.(int), noteID, req)
+	}
+}
+
+// AskNotes godoc
+// @Summary Ask a question across all notes
+// @Description Answer a question from the most relevant passages across all of the user's notes, with citations
+// @Tags Notes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body AskRequest true "Question"
+// @Success 200 {object} services.Answer "Answer with cited passages"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /ask [post]
+func askNotes(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req AskRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		answerQuestion(c, database, userID.(int), 0, req)
+	}
+}
+
+// answerQuestion retrieves passages for the question and writes the answer
+func answerQuestion(c *gin.Context, database *sql.DB, userID, noteID int, req AskRequest) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultAskPassages
+	}
+	if limit > maxAskPassages {
+		limit = maxAskPassages
+	}
+
+	passages, err := RetrievePassages(c.Request.Context(), database, userID, noteID, req.Question, limit)
+	if err != nil {
+		fmt.Printf("Failed to retrieve passages for user %d: %v\n", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search notes"})
+		return
+	}
+
+	answer, err := services.AnswerQuestion(c.Request.Context(), req.Question, passages)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer question"})
+		return
+	}
+
+	c.JSON(http.StatusOK, answer)
+}
+
+// RetrievePassages finds the passages of the user's notes most relevant to
+// query, limited to one note when noteID is non-zero. Notes uploaded before
+// chunking was introduced are split on the fly.
+func RetrievePassages(ctx context.Context, database *sql.DB, userID, noteID int, query string, limit int) ([]services.Passage, error) {
+	var embedding *pgvector.Vector
+	if db.VectorAvailable(database) {
+		queryEmbedding, err := services.GenerateEmbedding(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		embedding = &queryEmbedding
+	}
+
+	matches, err := db.SearchChunks(ctx, database, userID, noteID, query, embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	passages := make([]services.Passage, 0, len(matches))
+	for _, match := range matches {
+		passages = append(passages, services.Passage{
+			NoteID:      match.NoteID,
+			NoteTitle:   match.NoteTitle,
+			ChunkID:     match.ID,
+			ChunkIndex:  match.ChunkIndex,
+			StartOffset: match.StartOffset,
+			EndOffset:   match.EndOffset,
+			Text:        match.Content,
+		})
+	}
+
+	if len(passages) > 0 || noteID == 0 {
+		return passages, nil
+	}
+
+	// Fall back to splitting the note in memory
+	var note db.Note
+	err = database.QueryRowContext(ctx,
+		"SELECT id, title, content FROM notes WHERE id = $1 AND user_id = $2",
+		noteID, userID,
+	).Scan(&note.ID, &note.Title, &note.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunk := range services.ChunkText(note.Content, services.NoteChunkOptions) {
+		passages = append(passages, services.Passage{
+			NoteID:      note.ID,
+			NoteTitle:   note.Title,
+			ChunkIndex:  chunk.Index,
+			StartOffset: chunk.Start,
+			EndOffset:   chunk.End,
+			Text:        chunk.Text,
+		})
+	}
+
+	return services.RankPassages(query, passages, limit), nil
+}
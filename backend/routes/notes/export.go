@@ -0,0 +1,122 @@
+package notes
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"studypartner/db"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormats are the outline formats a note can be exported in, with
+// their content types and file extensions
+var exportFormats = map[string]struct {
+	contentType string
+	extension   string
+}{
+	"opml":       {"text/x-opml; charset=utf-8", "opml"},
+	"md-outline": {"text/markdown; charset=utf-8", "md"},
+}
+
+// ExportNote godoc
+// @Summary Export a note as a mind map
+// @Description Export the structure of a note's summary as an OPML document or a Markdown outline, for mind-mapping and outliner tools. Uses the summary in the given style, or else the most structured summary the note has (outline, bullets, cheat sheet, then the rest).
+// @Tags Notes
+// @Produce plain
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param format query string true "opml or md-outline"
+// @Param style query string false "Summary style to export"
+// @Success 200 {string} string "Exported outline"
+// @Failure 400 {object} map[string]string "Invalid format or style"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note or summary not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /notes/{id}/export [get]
+func exportNote(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		format, ok := exportFormats[c.Query("format")]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be opml or md-outline"})
+			return
+		}
+		style := c.Query("style")
+		if err := (services.SummaryOptions{Style: style}).Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id, title FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID, &note.Title)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		var summary string
+		err = database.QueryRow(
+			`SELECT content FROM summaries WHERE note_id = $1 AND ($2 = '' OR style = $2)
+			 ORDER BY CASE style WHEN 'outline' THEN 0 WHEN 'bullets' THEN 1 WHEN 'cheat_sheet' THEN 2 ELSE 3 END, updated_at DESC
+			 LIMIT 1`,
+			note.ID, style,
+		).Scan(&summary)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Summary not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch summary"})
+			return
+		}
+
+		title := strings.TrimSpace(note.Title)
+		if title == "" {
+			title = fmt.Sprintf("Note %d", note.ID)
+		}
+		outline := services.ParseOutline(title, summary)
+
+		var body string
+		if format.extension == "opml" {
+			body, err = services.OPML(outline)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export note"})
+				return
+			}
+		} else {
+			body = services.MarkdownOutline(outline)
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, exportFileName(title, note.ID), format.extension))
+		c.Data(http.StatusOK, format.contentType, []byte(body))
+	}
+}
+
+// exportFileName turns a note title into a safe file name
+func exportFileName(title string, noteID int) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '-', r == '_':
+			return r
+		case unicode.IsSpace(r):
+			return '-'
+		default:
+			return -1
+		}
+	}, title)
+	name = strings.Trim(name, "-_")
+	if name == "" {
+		return fmt.Sprintf("note-%d", noteID)
+	}
+	return name
+}
@@ -0,0 +1,23 @@
+package notes
+
+import "testing"
+
+func TestExportFileName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Photosynthesis", "Photosynthesis"},
+		{"Light reactions 2", "Light-reactions-2"},
+		{"Cell_biology - week 3", "Cell_biology---week-3"},
+		{"Résumé: \"Krebs\" cycle?", "Rsum-Krebs-cycle"},
+		{"  --Calvin cycle__  ", "Calvin-cycle"},
+		{"光合作用", "note-7"},
+		{"", "note-7"},
+	}
+	for _, tt := range tests {
+		if got := exportFileName(tt.title, 7); got != tt.want {
+			t.Errorf("exportFileName(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package notes
 import (
 	"database/sql"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -12,11 +14,13 @@ import (
 	"studypartner/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pgvector/pgvector-go"
 )
 
 type UploadRequest struct {
-	File string `json:"file" binding:"required"` // Base64 encoded file
-	Name string `json:"name" binding:"required"`
+	File string   `json:"file" binding:"required"` // Base64 encoded file
+	Name string   `json:"name" binding:"required"`
+	Tags []string `json:"tags"`
 }
 
 func SetupNotesRoutes(router *gin.RouterGroup, database *sql.DB) {
@@ -28,7 +32,14 @@ func SetupNotesRoutes(router *gin.RouterGroup, database *sql.DB) {
 		notes.GET("/:id", getNote(database))
 		notes.DELETE("/:id", deleteNote(database))
 		notes.POST("/search", searchNotes(database))
+		notes.POST("/:id/ask", askNote(database))
+		notes.PUT("/:id/tags", setNoteTags(database))
+		notes.GET("/tags", listTags(database))
+		notes.GET("/:id/export", exportNote(database))
 	}
+
+	// Cross-note questions
+	router.POST("/ask", middleware.AuthRequired(), askNotes(database))
 }
 
 // UploadNote godoc
@@ -97,16 +108,39 @@ func uploadNote(database *sql.DB) gin.HandlerFunc {
 			vectorAvailable = false
 		}
 
-		var note db.Note
+		// Split the note into passages so search can match text anywhere in it
+		chunks := services.ChunkText(content, services.NoteChunkOptions)
+
+		var embedding pgvector.Vector
+		chunkEmbeddings := make([]pgvector.Vector, len(chunks))
 		if vectorAvailable {
-			// Generate embedding and save with vector support
-			embedding, err := services.GenerateEmbedding(content)
+			// Generate embeddings up front so a provider failure doesn't leave
+			// a half-indexed note behind. The note and its chunks are embedded
+			// in batches rather than one request each.
+			texts := []string{content}
+			for _, chunk := range chunks {
+				texts = append(texts, chunk.Text)
+			}
+			embeddings, err := services.GenerateEmbeddings(c.Request.Context(), texts)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate embedding"})
+				c.JSON(http.StatusInternalServerError, embeddingFailure(err, "Failed to generate embedding"))
 				return
 			}
+			embedding = embeddings[0]
+			copy(chunkEmbeddings, embeddings[1:])
+		}
 
-			err = database.QueryRow(
+		tx, err := database.BeginTx(c.Request.Context(), nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
+			return
+		}
+		defer tx.Rollback()
+
+		var note db.Note
+		if vectorAvailable {
+			// Save with vector support
+			err = tx.QueryRow(
 				`INSERT INTO notes (user_id, title, content, file_type, file_name, file_size, embedding) 
 				 VALUES ($1, $2, $3, $4, $5, $6, $7) 
 				 RETURNING id, user_id, title, content, file_type, file_name, file_size, created_at, updated_at`,
@@ -114,7 +148,7 @@ func uploadNote(database *sql.DB) gin.HandlerFunc {
 			).Scan(&note.ID, &note.UserID, &note.Title, &note.Content, &note.FileType, &note.FileName, &note.FileSize, &note.CreatedAt, &note.UpdatedAt)
 		} else {
 			// Save note without embedding (vector extension not available)
-			err = database.QueryRow(
+			err = tx.QueryRow(
 				`INSERT INTO notes (user_id, title, content, file_type, file_name, file_size) 
 				 VALUES ($1, $2, $3, $4, $5, $6) 
 				 RETURNING id, user_id, title, content, file_type, file_name, file_size, created_at, updated_at`,
@@ -127,10 +161,55 @@ func uploadNote(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Save note chunks
+		for i, chunk := range chunks {
+			if vectorAvailable {
+				_, err = tx.Exec(
+					`INSERT INTO note_chunks (note_id, chunk_index, content, start_offset, end_offset, embedding)
+					 VALUES ($1, $2, $3, $4, $5, $6)`,
+					note.ID, chunk.Index, chunk.Text, chunk.Start, chunk.End, chunkEmbeddings[i],
+				)
+			} else {
+				_, err = tx.Exec(
+					`INSERT INTO note_chunks (note_id, chunk_index, content, start_offset, end_offset)
+					 VALUES ($1, $2, $3, $4, $5)`,
+					note.ID, chunk.Index, chunk.Text, chunk.Start, chunk.End,
+				)
+			}
+			if err != nil {
+				fmt.Printf("Failed to save chunk %d for note %d: %v\n", chunk.Index, note.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
+				return
+			}
+		}
+
+		note.Tags, err = saveTags(tx, note.ID, req.Tags)
+		if err != nil {
+			fmt.Printf("Failed to save tags for note %d: %v\n", note.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
+			return
+		}
+
 		c.JSON(http.StatusCreated, note)
 	}
 }
 
+// embeddingFailure builds the error response for a failed embedding. An
+// embedding model with the wrong number of dimensions is a configuration
+// problem, so it is reported as such rather than as a generic failure.
+func embeddingFailure(err error, message string) gin.H {
+	fmt.Printf("%s: %v\n", message, err)
+	if errors.Is(err, services.ErrEmbeddingDimensions) {
+		return gin.H{"error": fmt.Sprintf("%s: the embedding model must return %d-dimension vectors", message, services.EmbeddingDimensions)}
+	}
+	return gin.H{"error": message}
+}
+
 // GetUserNotes godoc
 // @Summary Get user notes
 // @Description Get all notes belonging to the authenticated user
@@ -200,6 +279,12 @@ func getNote(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		note.Tags, err = loadTags(database, note.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+			return
+		}
+
 		c.JSON(http.StatusOK, note)
 	}
 }
@@ -225,6 +310,27 @@ func deleteNote(database *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// SearchResult is a note matching a search query, with the passage that
+// matched best when the note has been split into chunks
+type SearchResult struct {
+	db.Note
+	Similarity float64       `json:"similarity"`
+	Passage    *db.NoteChunk `json:"passage,omitempty"`
+}
+
+// SearchNotes godoc
+// @Summary Search notes
+// @Description Search the authenticated user's notes, ranking each note by its best-matching passage
+// @Tags Notes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object true "Search query" example({"query": "photosynthesis"})
+// @Success 200 {array} SearchResult "Matching notes with the best passage"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /notes/search [post]
 func searchNotes(database *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
@@ -244,26 +350,33 @@ func searchNotes(database *sql.DB) gin.HandlerFunc {
 			vectorAvailable = false
 		}
 
-		var results []struct {
-			db.Note
-			Similarity float64 `json:"similarity"`
-		}
-
+		var rows *sql.Rows
 		if vectorAvailable {
 			// Generate embedding for search query and use vector search
-			queryEmbedding, err := services.GenerateEmbedding(req.Query)
+			queryEmbedding, err := services.GenerateEmbedding(c.Request.Context(), req.Query)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate query embedding"})
+				c.JSON(http.StatusInternalServerError, embeddingFailure(err, "Failed to generate query embedding"))
 				return
 			}
 
-			// Search using cosine similarity
-			rows, err := database.Query(
-				`SELECT id, user_id, title, content, file_type, file_name, file_size, created_at, updated_at,
-				 1 - (embedding <=> $1) as similarity
-				 FROM notes 
-				 WHERE user_id = $2 
-				 ORDER BY similarity DESC 
+			// Rank each note by its closest chunk, falling back to the note
+			// embedding for notes uploaded before chunking existed
+			rows, err = database.Query(
+				`WITH best AS (
+				   SELECT DISTINCT ON (c.note_id) c.note_id, c.id, c.chunk_index, c.content, c.start_offset, c.end_offset, c.created_at,
+				          1 - (c.embedding <=> $1) AS similarity
+				   FROM note_chunks c
+				   JOIN notes n ON n.id = c.note_id
+				   WHERE n.user_id = $2 AND c.embedding IS NOT NULL
+				   ORDER BY c.note_id, c.embedding <=> $1
+				 )
+				 SELECT n.id, n.user_id, n.title, n.content, n.file_type, n.file_name, n.file_size, n.created_at, n.updated_at,
+				        COALESCE(b.similarity, 1 - (n.embedding <=> $1)) AS similarity,
+				        b.id, b.chunk_index, b.content, b.start_offset, b.end_offset, b.created_at
+				 FROM notes n
+				 LEFT JOIN best b ON b.note_id = n.id
+				 WHERE n.user_id = $2
+				 ORDER BY similarity DESC NULLS LAST
 				 LIMIT 10`,
 				queryEmbedding, userID,
 			)
@@ -271,34 +384,28 @@ func searchNotes(database *sql.DB) gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search notes"})
 				return
 			}
-			defer rows.Close()
-
-			for rows.Next() {
-				var result struct {
-					db.Note
-					Similarity float64 `json:"similarity"`
-				}
-				err := rows.Scan(&result.ID, &result.UserID, &result.Title, &result.Content, &result.FileType, &result.FileName, &result.FileSize, &result.CreatedAt, &result.UpdatedAt, &result.Similarity)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan search result"})
-					return
-				}
-				results = append(results, result)
-			}
 		} else {
 			// Fallback to text search when vector extension is not available
-			rows, err := database.Query(
-				`SELECT id, user_id, title, content, file_type, file_name, file_size, created_at, updated_at,
+			rows, err = database.Query(
+				`SELECT n.id, n.user_id, n.title, n.content, n.file_type, n.file_name, n.file_size, n.created_at, n.updated_at,
 				 CASE 
-				   WHEN title ILIKE $1 THEN 1.0
-				   WHEN content ILIKE $1 THEN 0.8
-				   WHEN title ILIKE $2 THEN 0.6
-				   WHEN content ILIKE $2 THEN 0.4
+				   WHEN n.title ILIKE $1 THEN 1.0
+				   WHEN n.content ILIKE $1 THEN 0.8
+				   WHEN n.title ILIKE $2 THEN 0.6
+				   WHEN n.content ILIKE $2 THEN 0.4
 				   ELSE 0.0
-				 END as similarity
-				 FROM notes 
-				 WHERE user_id = $3 
-				   AND (title ILIKE $1 OR content ILIKE $1 OR title ILIKE $2 OR content ILIKE $2)
+				 END as similarity,
+				 b.id, b.chunk_index, b.content, b.start_offset, b.end_offset, b.created_at
+				 FROM notes n
+				 LEFT JOIN LATERAL (
+				   SELECT c.id, c.chunk_index, c.content, c.start_offset, c.end_offset, c.created_at
+				   FROM note_chunks c
+				   WHERE c.note_id = n.id AND (c.content ILIKE $1 OR c.content ILIKE $2)
+				   ORDER BY CASE WHEN c.content ILIKE $1 THEN 0 ELSE 1 END, c.chunk_index
+				   LIMIT 1
+				 ) b ON TRUE
+				 WHERE n.user_id = $3 
+				   AND (n.title ILIKE $1 OR n.content ILIKE $1 OR n.title ILIKE $2 OR n.content ILIKE $2)
 				 ORDER BY similarity DESC 
 				 LIMIT 10`,
 				"%"+req.Query+"%", "%"+strings.Join(strings.Split(req.Query, " "), "%")+"%", userID,
@@ -307,22 +414,50 @@ func searchNotes(database *sql.DB) gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search notes"})
 				return
 			}
-			defer rows.Close()
-
-			for rows.Next() {
-				var result struct {
-					db.Note
-					Similarity float64 `json:"similarity"`
-				}
-				err := rows.Scan(&result.ID, &result.UserID, &result.Title, &result.Content, &result.FileType, &result.FileName, &result.FileSize, &result.CreatedAt, &result.UpdatedAt, &result.Similarity)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan search result"})
-					return
-				}
-				results = append(results, result)
+		}
+		defer rows.Close()
+
+		var results []SearchResult
+		for rows.Next() {
+			result, err := scanSearchResult(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan search result"})
+				return
 			}
+			results = append(results, result)
 		}
 
 		c.JSON(http.StatusOK, results)
 	}
 }
+
+// scanSearchResult scans a note, its similarity and the optional best
+// passage from a search query row
+func scanSearchResult(rows *sql.Rows) (SearchResult, error) {
+	var result SearchResult
+	var similarity sql.NullFloat64
+	var chunkID, chunkIndex, startOffset, endOffset sql.NullInt64
+	var chunkContent sql.NullString
+	var chunkCreatedAt sql.NullTime
+
+	err := rows.Scan(&result.ID, &result.UserID, &result.Title, &result.Content, &result.FileType, &result.FileName, &result.FileSize, &result.CreatedAt, &result.UpdatedAt,
+		&similarity, &chunkID, &chunkIndex, &chunkContent, &startOffset, &endOffset, &chunkCreatedAt)
+	if err != nil {
+		return result, err
+	}
+
+	result.Similarity = similarity.Float64
+	if chunkID.Valid {
+		result.Passage = &db.NoteChunk{
+			ID:          int(chunkID.Int64),
+			NoteID:      result.ID,
+			ChunkIndex:  int(chunkIndex.Int64),
+			Content:     chunkContent.String,
+			StartOffset: int(startOffset.Int64),
+			EndOffset:   int(endOffset.Int64),
+			CreatedAt:   chunkCreatedAt.Time,
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,171 @@
+package notes
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTagLength matches note_tags.tag
+const maxTagLength = 100
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Notes int    `json:"notes"`
+}
+
+// SetNoteTags godoc
+// @Summary Set note tags
+// @Description Replace the tags on a note. Tags are trimmed and lowercased.
+// @Tags Notes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param request body TagsRequest true "New tags"
+// @Success 200 {object} TagsRequest "Saved tags"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /notes/{id}/tags [put]
+func setNoteTags(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req TagsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Check if note belongs to user
+		var noteID int
+		err := database.QueryRow(
+			"SELECT id FROM notes WHERE id = $1 AND user_id = $2",
+			c.Param("id"), userID,
+		).Scan(&noteID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		tx, err := database.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("DELETE FROM note_tags WHERE note_id = $1", noteID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+			return
+		}
+		tags, err := saveTags(tx, noteID, req.Tags)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			fmt.Printf("Failed to save tags for note %d: %v\n", noteID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+			return
+		}
+
+		c.JSON(http.StatusOK, TagsRequest{Tags: tags})
+	}
+}
+
+// ListTags godoc
+// @Summary List tags
+// @Description List every tag on the user's notes with how many notes carry it
+// @Tags Notes
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} TagCount "Tags in alphabetical order"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /notes/tags [get]
+func listTags(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		rows, err := database.Query(
+			`SELECT t.tag, COUNT(*) FROM note_tags t JOIN notes n ON n.id = t.note_id
+			 WHERE n.user_id = $1 GROUP BY t.tag ORDER BY t.tag`,
+			userID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+			return
+		}
+		defer rows.Close()
+
+		tags := []TagCount{}
+		for rows.Next() {
+			var tag TagCount
+			if err := rows.Scan(&tag.Tag, &tag.Notes); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan tag"})
+				return
+			}
+			tags = append(tags, tag)
+		}
+
+		c.JSON(http.StatusOK, tags)
+	}
+}
+
+// saveTags adds the normalized tags to a note and returns them
+func saveTags(tx *sql.Tx, noteID int, tags []string) ([]string, error) {
+	tags = normalizeTags(tags)
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT INTO note_tags (note_id, tag) VALUES ($1, $2)", noteID, tag); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// loadTags fetches a note's tags in alphabetical order
+func loadTags(database *sql.DB, noteID int) ([]string, error) {
+	rows, err := database.Query("SELECT tag FROM note_tags WHERE note_id = $1 ORDER BY tag", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// normalizeTags trims, lowercases and de-duplicates tags, dropping empty
+// ones and cutting long ones to fit the column
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if runes := []rune(tag); len(runes) > maxTagLength {
+			tag = strings.TrimSpace(string(runes[:maxTagLength]))
+		}
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
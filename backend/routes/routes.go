@@ -4,6 +4,7 @@ import (
 	"database/sql"
 
 	"studypartner/routes/auth"
+	"studypartner/routes/jobs"
 	"studypartner/routes/notes"
 	"studypartner/routes/study"
 
@@ -22,5 +23,8 @@ func SetupRoutes(router *gin.Engine, db *sql.DB) {
 		
 		// Study routes
 		study.SetupStudyRoutes(api, db)
+
+		// Background job status
+		jobs.SetupJobsRoutes(api, db)
 	}
 }
@@ -0,0 +1,251 @@
+package study
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"studypartner/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Adaptive quiz parameters
+const (
+	defaultAdaptiveCount = 10
+	maxAdaptiveCount     = 50
+
+	// mistakeHalfLife is how long it takes a wrong answer's boost to halve
+	mistakeHalfLife = 7 * 24 * time.Hour
+)
+
+// AdaptiveQuizOptions chooses the questions of an adaptive quiz
+type AdaptiveQuizOptions struct {
+	NoteIDs []int64 `json:"note_ids,omitempty"` // Notes to draw from; every note when empty
+	Count   int     `json:"count,omitempty"`    // Questions to ask, 10 by default
+}
+
+// Validate checks the number of questions
+func (o AdaptiveQuizOptions) Validate() error {
+	if o.Count < 0 || o.Count > maxAdaptiveCount {
+		return fmt.Errorf("count must be between 0 (the default) and %d", maxAdaptiveCount)
+	}
+	return nil
+}
+
+// AdaptiveQuestion is a question of an adaptive quiz, without its answer,
+// with the user's record on it
+type AdaptiveQuestion struct {
+	QuizQuestion
+	Attempts  int     `json:"attempts"`   // Times the user has answered it
+	Wrong     int     `json:"wrong"`      // Times they got it wrong
+	ErrorRate float64 `json:"error_rate"` // Estimated chance of getting it wrong, from 0 to 1
+}
+
+// AdaptiveQuiz is a quiz session over the questions a user is weakest on
+type AdaptiveQuiz struct {
+	Session   db.StudySession    `json:"session"`
+	Questions []AdaptiveQuestion `json:"questions"`
+}
+
+// questionRecord is a quiz question with the user's answers to it
+type questionRecord struct {
+	quiz      db.Quiz
+	attempts  int
+	wrong     int
+	wrongAge  *float64 // Hours since the last wrong answer
+	errorRate float64
+	weight    float64
+}
+
+// CreateAdaptiveQuiz godoc
+// @Summary Create an adaptive quiz
+// @Description Start a quiz session mixing questions from across the user's notes, picked at random weighted towards those they get wrong most, and most recently. Questions never answered count as likely to be missed as the rest of their note's quiz. Answer it like any quiz with POST /study/sessions/{id}/answers.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body AdaptiveQuizOptions false "Notes to draw from and number of questions"
+// @Success 201 {object} AdaptiveQuiz "Quiz session and its questions"
+// @Failure 400 {object} map[string]string "Invalid options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "No quiz questions to choose from"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/adaptive-quiz [post]
+func createAdaptiveQuiz(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var opts AdaptiveQuizOptions
+		if err := bindOptions(c, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if opts.Count == 0 {
+			opts.Count = defaultAdaptiveCount
+		}
+
+		records, err := loadQuestionRecords(database, userID.(int), opts.NoteIDs)
+		if err != nil {
+			fmt.Printf("Failed to load quiz history for user %v: %v\n", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiz questions"})
+			return
+		}
+		if len(records) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No quiz questions found; generate a quiz for your notes first"})
+			return
+		}
+
+		picked := pickWeighted(records, opts.Count, rand.New(rand.NewSource(time.Now().UnixNano())))
+		quizIDs := make([]int64, len(picked))
+		questions := make([]db.Quiz, len(picked))
+		for i, record := range picked {
+			quizIDs[i] = int64(record.quiz.ID)
+			questions[i] = record.quiz
+		}
+
+		session, err := scanSession(database.QueryRow(
+			"INSERT INTO study_sessions (user_id, type, quiz_ids) VALUES ($1, 'adaptive_quiz', $2) RETURNING "+sessionColumns,
+			userID, pq.Array(quizIDs),
+		))
+		if err != nil {
+			fmt.Printf("Failed to create adaptive quiz for user %v: %v\n", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study session"})
+			return
+		}
+
+		quiz := AdaptiveQuiz{Session: session, Questions: make([]AdaptiveQuestion, 0, len(picked))}
+		for i, question := range hideAnswers(questions) {
+			record := picked[i]
+			quiz.Questions = append(quiz.Questions, AdaptiveQuestion{
+				QuizQuestion: question,
+				Attempts:     record.attempts,
+				Wrong:        record.wrong,
+				ErrorRate:    math.Round(record.errorRate*100) / 100,
+			})
+		}
+
+		c.JSON(http.StatusCreated, quiz)
+	}
+}
+
+// loadQuestionRecords fetches the quiz questions of the user's notes, or of
+// the given notes, with how the user has done on each, and weighs them.
+// Answers are matched to questions by the note and question they were
+// given to, so a question keeps its record when its quiz is regenerated.
+// The age of the last wrong answer is worked out by the database, against
+// the clock that stamped it.
+func loadQuestionRecords(database *sql.DB, userID int, noteIDs []int64) ([]*questionRecord, error) {
+	if len(noteIDs) == 0 {
+		noteIDs = nil
+	}
+	rows, err := database.Query(
+		`SELECT `+quizColumns+`, COALESCE(h.attempts, 0), COALESCE(h.wrong, 0),
+		   EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - h.last_wrong)::float8 / 3600
+		 FROM quizzes
+		 LEFT JOIN (
+		   SELECT COALESCE((a.question_snapshot->>'note_id')::int, q.note_id) AS note_id,
+		     lower(COALESCE(a.question_snapshot->>'question', q.question)) AS question,
+		     COUNT(*) AS attempts, COUNT(*) FILTER (WHERE NOT a.correct) AS wrong,
+		     MAX(a.created_at) FILTER (WHERE NOT a.correct) AS last_wrong
+		   FROM quiz_attempt_answers a
+		   JOIN study_sessions s ON s.id = a.session_id
+		   LEFT JOIN quizzes q ON q.id = a.quiz_id
+		   WHERE s.user_id = $1
+		   GROUP BY 1, 2
+		 ) h ON h.note_id = quizzes.note_id AND h.question = lower(quizzes.question)
+		 WHERE note_id IN (SELECT id FROM notes WHERE user_id = $1 AND ($2::int[] IS NULL OR id = ANY($2::int[])))`,
+		userID, pq.Array(noteIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*questionRecord
+	for rows.Next() {
+		record := &questionRecord{}
+		quiz, err := scanQuiz(extraScanner{rows, []interface{}{&record.attempts, &record.wrong, &record.wrongAge}})
+		if err != nil {
+			return nil, err
+		}
+		record.quiz = quiz
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	weighQuestions(records)
+	return records, nil
+}
+
+// extraScanner reads columns selected after those of a scan helper into
+// extra
+type extraScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// weighQuestions estimates how likely the user is to get each question
+// wrong, and weighs the questions for picking. The estimate blends the
+// question's own record with its note's, so a question seen a few times,
+// or never, leans on how the user does on the rest of the note. The weight
+// is the estimate, raised by up to double for a recent wrong answer.
+func weighQuestions(records []*questionRecord) {
+	type tally struct{ attempts, wrong int }
+	notes := map[int]*tally{}
+	for _, record := range records {
+		t := notes[record.quiz.NoteID]
+		if t == nil {
+			t = &tally{}
+			notes[record.quiz.NoteID] = t
+		}
+		t.attempts += record.attempts
+		t.wrong += record.wrong
+	}
+
+	for _, record := range records {
+		note := notes[record.quiz.NoteID]
+		noteRate := (float64(note.wrong) + 1) / (float64(note.attempts) + 2)
+		record.errorRate = (float64(record.wrong) + 2*noteRate) / (float64(record.attempts) + 2)
+
+		recency := 0.0
+		if record.wrongAge != nil {
+			recency = math.Pow(0.5, math.Max(*record.wrongAge, 0)/mistakeHalfLife.Hours())
+		}
+		record.weight = record.errorRate * (1 + recency)
+	}
+}
+
+// pickWeighted draws count records at random without replacement, each
+// with a chance in proportion to its weight, and returns them weakest first
+func pickWeighted(records []*questionRecord, count int, r *rand.Rand) []*questionRecord {
+	// Weighted sampling by Efraimidis and Spirakis: keep the records with
+	// the largest random keys u^(1/weight)
+	keys := make(map[*questionRecord]float64, len(records))
+	for _, record := range records {
+		keys[record] = math.Pow(r.Float64(), 1/math.Max(record.weight, 1e-6))
+	}
+	picked := append([]*questionRecord(nil), records...)
+	sort.Slice(picked, func(i, j int) bool {
+		return keys[picked[i]] > keys[picked[j]]
+	})
+	if len(picked) > count {
+		picked = picked[:count]
+	}
+
+	sort.SliceStable(picked, func(i, j int) bool {
+		return picked[i].weight > picked[j].weight
+	})
+	return picked
+}
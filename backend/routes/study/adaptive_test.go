@@ -0,0 +1,113 @@
+package study
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"studypartner/db"
+)
+
+func hoursAgo(h float64) *float64 {
+	return &h
+}
+
+func record(id, noteID, attempts, wrong int, wrongAge *float64) *questionRecord {
+	return &questionRecord{quiz: db.Quiz{ID: id, NoteID: noteID}, attempts: attempts, wrong: wrong, wrongAge: wrongAge}
+}
+
+func TestWeighQuestions(t *testing.T) {
+	halfLife := mistakeHalfLife.Hours()
+	tests := []struct {
+		name       string
+		records    []*questionRecord
+		errorRates []float64
+		weights    []float64
+	}{
+		{"never answered", []*questionRecord{record(1, 1, 0, 0, nil)}, []float64{0.5}, []float64{0.5}},
+		{"always right", []*questionRecord{record(1, 1, 4, 0, nil)}, []float64{1.0 / 18}, []float64{1.0 / 18}},
+		{"wrong just now", []*questionRecord{record(1, 1, 2, 2, hoursAgo(0))}, []float64{0.875}, []float64{1.75}},
+		{"wrong a half-life ago", []*questionRecord{record(1, 1, 2, 2, hoursAgo(halfLife))}, []float64{0.875}, []float64{1.3125}},
+		{"wrong long ago", []*questionRecord{record(1, 1, 2, 2, hoursAgo(100*halfLife))}, []float64{0.875}, []float64{0.875}},
+		{"clock skew", []*questionRecord{record(1, 1, 2, 2, hoursAgo(-1))}, []float64{0.875}, []float64{1.75}},
+		{
+			"leans on the note",
+			[]*questionRecord{record(1, 1, 4, 0, nil), record(2, 1, 0, 0, nil)},
+			[]float64{1.0 / 18, 1.0 / 6},
+			[]float64{1.0 / 18, 1.0 / 6},
+		},
+		{
+			"other notes don't count",
+			[]*questionRecord{record(1, 1, 4, 4, nil), record(2, 2, 0, 0, nil)},
+			[]float64{(4 + 2*5.0/6) / 6, 0.5},
+			[]float64{(4 + 2*5.0/6) / 6, 0.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weighQuestions(tt.records)
+			for i, record := range tt.records {
+				if math.Abs(record.errorRate-tt.errorRates[i]) > 1e-9 {
+					t.Errorf("question %d: error rate = %v, want %v", record.quiz.ID, record.errorRate, tt.errorRates[i])
+				}
+				if math.Abs(record.weight-tt.weights[i]) > 1e-9 {
+					t.Errorf("question %d: weight = %v, want %v", record.quiz.ID, record.weight, tt.weights[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPickWeighted(t *testing.T) {
+	records := []*questionRecord{
+		{quiz: db.Quiz{ID: 1}, weight: 0.2},
+		{quiz: db.Quiz{ID: 2}, weight: 1.5},
+		{quiz: db.Quiz{ID: 3}, weight: 0},
+		{quiz: db.Quiz{ID: 4}, weight: 0.8},
+	}
+	tests := []struct {
+		name  string
+		count int
+		want  int
+	}{
+		{"fewer than there are", 2, 2},
+		{"all of them", 4, 4},
+		{"more than there are", 10, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := pickWeighted(records, tt.count, rand.New(rand.NewSource(1)))
+			if len(picked) != tt.want {
+				t.Fatalf("picked %d questions, want %d", len(picked), tt.want)
+			}
+			seen := map[int]bool{}
+			for i, record := range picked {
+				if seen[record.quiz.ID] {
+					t.Errorf("question %d picked twice", record.quiz.ID)
+				}
+				seen[record.quiz.ID] = true
+				if i > 0 && record.weight > picked[i-1].weight {
+					t.Errorf("question %d comes after a lighter one", record.quiz.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestPickWeightedFavorsHeavier(t *testing.T) {
+	records := []*questionRecord{
+		{quiz: db.Quiz{ID: 1}, weight: 0.1},
+		{quiz: db.Quiz{ID: 2}, weight: 0.9},
+	}
+	r := rand.New(rand.NewSource(1))
+	heavier := 0
+	for i := 0; i < 1000; i++ {
+		if pickWeighted(records, 1, r)[0].quiz.ID == 2 {
+			heavier++
+		}
+	}
+	// The heavier question should be picked about 90% of the time
+	if heavier < 850 || heavier > 950 {
+		t.Errorf("heavier question picked %d times in 1000, want about 900", heavier)
+	}
+}
@@ -0,0 +1,315 @@
+package study
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"studypartner/db"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// ConceptGraph is the stored concept graph of a note, or of all of a user's
+// notes
+type ConceptGraph struct {
+	Concepts  []db.Concept         `json:"concepts"`
+	Relations []db.ConceptRelation `json:"relations"`
+}
+
+// graphFormats are the formats a concept graph can be exported in, with
+// their content types
+var graphFormats = map[string]string{
+	"json":    "application/json; charset=utf-8",
+	"mermaid": "text/vnd.mermaid; charset=utf-8",
+	"dot":     "text/vnd.graphviz; charset=utf-8",
+}
+
+// GetNoteConcepts godoc
+// @Summary Get note concept map
+// @Description Get the concepts of a note and how they relate (is_a, part_of, causes, prerequisite), as JSON or exported for Mermaid or Graphviz
+// @Tags Study Materials
+// @Produce json,plain
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param format query string false "json (default), mermaid or dot"
+// @Success 200 {object} ConceptGraph "Concept graph"
+// @Failure 400 {object} map[string]string "Invalid format"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/concepts [get]
+func getNoteConcepts(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		format := c.DefaultQuery("format", "json")
+		if _, ok := graphFormats[format]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json, mermaid or dot"})
+			return
+		}
+
+		// Check if note belongs to user
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		graph, err := loadConceptGraph(database, userID.(int), note.ID)
+		if err != nil {
+			fmt.Printf("Failed to load concept map for note %d: %v\n", note.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch concept map"})
+			return
+		}
+
+		writeConceptGraph(c, format, graph)
+	}
+}
+
+// GetConcepts godoc
+// @Summary Get concept map across notes
+// @Description Get the concepts of all of the user's notes and how they relate, joined where notes share a concept, as JSON or exported for Mermaid or Graphviz
+// @Tags Study Materials
+// @Produce json,plain
+// @Security BearerAuth
+// @Param format query string false "json (default), mermaid or dot"
+// @Success 200 {object} ConceptGraph "Concept graph"
+// @Failure 400 {object} map[string]string "Invalid format"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/concepts [get]
+func getConcepts(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		format := c.DefaultQuery("format", "json")
+		if _, ok := graphFormats[format]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json, mermaid or dot"})
+			return
+		}
+
+		graph, err := loadConceptGraph(database, userID.(int), 0)
+		if err != nil {
+			fmt.Printf("Failed to load concept map for user %v: %v\n", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch concept map"})
+			return
+		}
+
+		writeConceptGraph(c, format, graph)
+	}
+}
+
+// GenerateConcepts godoc
+// @Summary Generate note concept map
+// @Description Extract the concepts of a note and how they relate, replacing the note's part of the user's concept graph
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param async query bool false "Set to false to wait for the result instead of queuing the work" default(true)
+// @Success 202 {object} db.Job "Queued job"
+// @Success 200 {object} ConceptGraph "Concept graph of the note"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/concepts [post]
+func generateConcepts(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		// Check if note belongs to user and get content
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id, content FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID, &note.Content)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		// Run in the background unless asked to wait, replying with the job
+		// to poll
+		if c.Query("async") != "false" {
+			enqueueJob(c, database, note.ID, jobTypeConcepts, nil)
+			return
+		}
+
+		extracted, err := services.ExtractConcepts(c.Request.Context(), note.Content)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate concept map"})
+			return
+		}
+
+		graph, err := replaceNoteConcepts(database, nil, userID.(int), note.ID, extracted)
+		if err != nil {
+			fmt.Printf("Failed to save concept map for note %d: %v\n", note.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save concept map"})
+			return
+		}
+
+		c.JSON(http.StatusOK, graph)
+	}
+}
+
+// writeConceptGraph replies with the graph in format, one of graphFormats
+func writeConceptGraph(c *gin.Context, format string, graph ConceptGraph) {
+	switch format {
+	case "mermaid":
+		c.Data(http.StatusOK, graphFormats[format], []byte(services.MermaidGraph(graph.export())))
+	case "dot":
+		c.Data(http.StatusOK, graphFormats[format], []byte(services.DOTGraph(graph.export())))
+	default:
+		c.JSON(http.StatusOK, graph)
+	}
+}
+
+// export names the ends of each relation for the graph exporters
+func (g ConceptGraph) export() services.ConceptGraph {
+	names := make(map[int]string, len(g.Concepts))
+	exported := services.ConceptGraph{}
+	for _, concept := range g.Concepts {
+		names[concept.ID] = concept.Name
+		exported.Concepts = append(exported.Concepts, services.ConceptData{Name: concept.Name, Description: concept.Description})
+	}
+	for _, relation := range g.Relations {
+		exported.Relations = append(exported.Relations, services.RelationData{
+			Source: names[relation.SourceID],
+			Target: names[relation.TargetID],
+			Type:   relation.Relation,
+		})
+	}
+	return exported
+}
+
+// loadConceptGraph reads the concept graph of a note, or of all of the
+// user's notes when noteID is 0
+func loadConceptGraph(database *sql.DB, userID, noteID int) (ConceptGraph, error) {
+	graph := ConceptGraph{Concepts: []db.Concept{}, Relations: []db.ConceptRelation{}}
+
+	rows, err := database.Query(
+		`SELECT c.id, c.name, COALESCE(c.description, ''), array_agg(nc.note_id ORDER BY nc.note_id)
+		 FROM concepts c JOIN note_concepts nc ON nc.concept_id = c.id
+		 WHERE c.user_id = $1 AND ($2 = 0 OR c.id IN (SELECT concept_id FROM note_concepts WHERE note_id = $2))
+		 GROUP BY c.id ORDER BY c.name`,
+		userID, noteID,
+	)
+	if err != nil {
+		return graph, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var concept db.Concept
+		if err := rows.Scan(&concept.ID, &concept.Name, &concept.Description, pq.Array(&concept.NoteIDs)); err != nil {
+			return graph, err
+		}
+		graph.Concepts = append(graph.Concepts, concept)
+	}
+	if err := rows.Err(); err != nil {
+		return graph, err
+	}
+
+	rows, err = database.Query(
+		`SELECT r.source_id, r.target_id, r.relation, array_agg(DISTINCT r.note_id)
+		 FROM concept_relations r JOIN concepts c ON c.id = r.source_id
+		 WHERE c.user_id = $1 AND ($2 = 0 OR r.note_id = $2)
+		 GROUP BY r.source_id, r.target_id, r.relation
+		 ORDER BY r.source_id, r.target_id, r.relation`,
+		userID, noteID,
+	)
+	if err != nil {
+		return graph, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var relation db.ConceptRelation
+		if err := rows.Scan(&relation.SourceID, &relation.TargetID, &relation.Relation, pq.Array(&relation.NoteIDs)); err != nil {
+			return graph, err
+		}
+		graph.Relations = append(graph.Relations, relation)
+	}
+	return graph, rows.Err()
+}
+
+// replaceNoteConcepts swaps a note's concepts and relations for newly
+// extracted ones. Concepts are matched to the user's existing ones by name,
+// and concepts no note mentions any more are removed.
+func replaceNoteConcepts(database *sql.DB, job *db.Job, userID, noteID int, extracted services.ConceptGraph) (ConceptGraph, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return ConceptGraph{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockJob(tx, job); err != nil {
+		return ConceptGraph{}, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM note_concepts WHERE note_id = $1", noteID); err != nil {
+		return ConceptGraph{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM concept_relations WHERE note_id = $1", noteID); err != nil {
+		return ConceptGraph{}, err
+	}
+
+	ids := map[string]int{}
+	for _, concept := range extracted.Concepts {
+		key := services.ConceptKey(concept.Name)
+		var id int
+		err := tx.QueryRow(
+			`INSERT INTO concepts (user_id, name, key, description) VALUES ($1, $2, $3, NULLIF($4, ''))
+			 ON CONFLICT (user_id, key) DO UPDATE SET description = COALESCE(concepts.description, EXCLUDED.description)
+			 RETURNING id`,
+			userID, concept.Name, key, concept.Description,
+		).Scan(&id)
+		if err != nil {
+			return ConceptGraph{}, err
+		}
+		ids[key] = id
+
+		if _, err := tx.Exec(
+			"INSERT INTO note_concepts (note_id, concept_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			noteID, id,
+		); err != nil {
+			return ConceptGraph{}, err
+		}
+	}
+
+	for _, relation := range extracted.Relations {
+		source, okSource := ids[services.ConceptKey(relation.Source)]
+		target, okTarget := ids[services.ConceptKey(relation.Target)]
+		if !okSource || !okTarget {
+			continue
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO concept_relations (note_id, source_id, target_id, relation) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT DO NOTHING`,
+			noteID, source, target, relation.Type,
+		); err != nil {
+			return ConceptGraph{}, err
+		}
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM concepts c WHERE c.user_id = $1
+		 AND NOT EXISTS (SELECT 1 FROM note_concepts nc WHERE nc.concept_id = c.id)`,
+		userID,
+	); err != nil {
+		return ConceptGraph{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ConceptGraph{}, err
+	}
+	return loadConceptGraph(database, userID, noteID)
+}
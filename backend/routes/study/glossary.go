@@ -0,0 +1,271 @@
+package study
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"studypartner/db"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// termColumns are the term columns read by scanTerm
+const termColumns = "id, note_id, term, definition, source_start, source_end, created_at"
+
+// GetGlossary godoc
+// @Summary Get note glossary
+// @Description Get the key terms extracted from a note with their definitions, most important first
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Success 200 {array} db.Term "Glossary terms"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/glossary [get]
+func getGlossary(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		// Check if note belongs to user
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		terms, err := loadTerms(database, noteID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch glossary"})
+			return
+		}
+
+		c.JSON(http.StatusOK, terms)
+	}
+}
+
+// GenerateGlossary godoc
+// @Summary Generate note glossary
+// @Description Extract the key terms of a note with their definitions, replacing its existing glossary. Uses a TF-IDF ranking of the note's phrases when the AI provider is unavailable.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param async query bool false "Set to false to wait for the result instead of queuing the work" default(true)
+// @Success 202 {object} db.Job "Queued job"
+// @Success 200 {array} db.Term "Extracted terms"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/glossary [post]
+func generateGlossary(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		// Check if note belongs to user and get content
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id, content FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID, &note.Content)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		// Run in the background unless asked to wait, replying with the job
+		// to poll
+		if c.Query("async") != "false" {
+			enqueueJob(c, database, note.ID, jobTypeGlossary, nil)
+			return
+		}
+
+		terms, err := services.ExtractGlossary(c.Request.Context(), note.Content)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate glossary"})
+			return
+		}
+
+		insertedTerms, err := replaceTerms(database, nil, noteID, terms)
+		if err != nil {
+			fmt.Printf("Failed to save glossary for note %s: %v\n", noteID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save glossary"})
+			return
+		}
+
+		c.JSON(http.StatusOK, insertedTerms)
+	}
+}
+
+// GlossaryFlashcards godoc
+// @Summary Turn glossary terms into flashcards
+// @Description Add a flashcard for each glossary term of a note, asking for the term's meaning. The note's other flashcards are kept, and terms that already have a card are skipped.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Success 201 {array} db.Flashcard "Added flashcards"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note or glossary not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/glossary/flashcards [post]
+func glossaryFlashcards(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		// Check if note belongs to user
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		terms, err := loadTerms(database, noteID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch glossary"})
+			return
+		}
+		if len(terms) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Glossary not found"})
+			return
+		}
+
+		flashcards, err := addTermFlashcards(database, noteID, terms)
+		if err != nil {
+			fmt.Printf("Failed to save glossary flashcards for note %s: %v\n", noteID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save flashcards"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, flashcards)
+	}
+}
+
+// termQuestion is the flashcard question asking for a term's meaning
+func termQuestion(term string) string {
+	return fmt.Sprintf("What is meant by %q?", term)
+}
+
+// addTermFlashcards adds a flashcard for each term that doesn't have one
+func addTermFlashcards(database *sql.DB, noteID string, terms []db.Term) ([]db.Flashcard, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT question FROM flashcards WHERE note_id = $1", noteID)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var question string
+		if err := rows.Scan(&question); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[question] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	insertedFlashcards := []db.Flashcard{}
+	for _, term := range terms {
+		question := termQuestion(term.Term)
+		if existing[question] {
+			continue
+		}
+
+		card := services.FlashcardData{Question: question, Answer: term.Definition}
+		if term.SourceStart != nil && term.SourceEnd != nil {
+			card.Span = &services.SourceSpan{Start: *term.SourceStart, End: *term.SourceEnd}
+		}
+		flashcard, err := insertFlashcard(tx, noteID, card)
+		if err != nil {
+			return nil, err
+		}
+		insertedFlashcards = append(insertedFlashcards, flashcard)
+	}
+
+	return insertedFlashcards, tx.Commit()
+}
+
+// loadTerms returns the glossary of a note in the order it was extracted
+func loadTerms(database *sql.DB, noteID string) ([]db.Term, error) {
+	rows, err := database.Query("SELECT "+termColumns+" FROM terms WHERE note_id = $1 ORDER BY id", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []db.Term{}
+	for rows.Next() {
+		term, err := scanTerm(rows)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+// replaceTerms swaps a note's glossary for newly extracted terms
+func replaceTerms(database *sql.DB, job *db.Job, noteID string, terms []services.TermData) ([]db.Term, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockJob(tx, job); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM terms WHERE note_id = $1", noteID); err != nil {
+		return nil, err
+	}
+
+	insertedTerms := []db.Term{}
+	for _, t := range terms {
+		start, end := sourceOffsets(t.Span)
+		term, err := scanTerm(tx.QueryRow(
+			`INSERT INTO terms (note_id, term, definition, source_start, source_end)
+			 VALUES ($1, $2, $3, $4, $5)
+			 RETURNING `+termColumns,
+			noteID, t.Term, t.Definition, start, end,
+		))
+		if err != nil {
+			return nil, err
+		}
+		insertedTerms = append(insertedTerms, term)
+	}
+
+	return insertedTerms, tx.Commit()
+}
+
+// scanTerm reads a term selected with termColumns
+func scanTerm(row rowScanner) (db.Term, error) {
+	var term db.Term
+	err := row.Scan(&term.ID, &term.NoteID, &term.Term, &term.Definition, &term.SourceStart, &term.SourceEnd, &term.CreatedAt)
+	return term, err
+}
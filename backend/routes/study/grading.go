@@ -0,0 +1,492 @@
+package study
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"studypartner/db"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type QuizAnswer struct {
+	QuizID     int    `json:"quiz_id" binding:"required"`
+	Selected   *int   `json:"selected"`   // Index of the chosen option, for multiple choice and true/false
+	Selections []int  `json:"selections"` // Every chosen option, for multi-select
+	Text       string `json:"text"`       // Written answer, for cloze, short answer and essay
+}
+
+type SubmitAnswersRequest struct {
+	Answers []QuizAnswer `json:"answers" binding:"required,min=1,dive"`
+}
+
+// GradedSession is a quiz session with every answer submitted so far
+type GradedSession struct {
+	Session db.StudySession        `json:"session"`
+	Answers []db.QuizAttemptAnswer `json:"answers"`
+	Correct int                    `json:"correct"`
+	Total   int                    `json:"total"`           // Questions in the quiz
+	Stale   bool                   `json:"stale,omitempty"` // Some questions were removed by regenerating the quiz
+	Quiz    []db.Quiz              `json:"quiz,omitempty"`  // The full answer key, once the session is completed
+}
+
+// errStaleSession is returned for a quiz session some of whose questions
+// were removed by regenerating their quiz
+var errStaleSession = errors.New("quiz session has questions that no longer exist")
+
+// QuizQuestion is a quiz question as shown while taking the quiz, without
+// its answer
+type QuizQuestion struct {
+	ID        int       `json:"id"`
+	NoteID    int       `json:"note_id"`
+	Type      string    `json:"type"`
+	Question  string    `json:"question"`
+	Options   []string  `json:"options"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SubmitAnswers godoc
+// @Summary Submit quiz answers
+// @Description Grade answers to questions in a quiz session: "selected" for multiple choice and true/false, "selections" for multi-select and "text" for cloze, short answer and essay. Short answers and essays are marked against their rubric by the AI provider, with a score, feedback and the key points missed. Answers can be sent one at a time or all at once, but each question is answered once: answering it again, or answering in a completed session, is refused. The session score is the average mark across the quiz, with choice and cloze questions marked 0 or 100, and the session is completed once every question has an answer.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Study session ID"
+// @Param request body SubmitAnswersRequest true "Chosen options"
+// @Success 200 {object} GradedSession "Graded answers and score"
+// @Failure 400 {object} map[string]string "Invalid answers"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Study session not found"
+// @Failure 409 {object} map[string]string "Session completed, question already answered or quiz regenerated"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/sessions/{id}/answers [post]
+func submitAnswers(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req SubmitAnswersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		session, err := scanSession(database.QueryRow(
+			"SELECT "+sessionColumns+" FROM study_sessions WHERE id = $1 AND user_id = $2",
+			c.Param("id"), userID,
+		))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study session not found"})
+			return
+		}
+		if session.Type != "quiz" && session.Type != "adaptive_quiz" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Answers can only be submitted to quiz sessions"})
+			return
+		}
+		if session.Completed {
+			c.JSON(http.StatusConflict, gin.H{"error": "This quiz session is already completed"})
+			return
+		}
+
+		questions, err := loadSessionQuestions(database, session)
+		if errors.Is(err, errStaleSession) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The quiz was regenerated after this session started; start a new session"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiz"})
+			return
+		}
+
+		answered, err := answeredQuestions(database, session.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch answers"})
+			return
+		}
+
+		// Check every answer before storing any of them
+		correct := make([]bool, len(req.Answers))
+		for i, answer := range req.Answers {
+			question, ok := questions[answer.QuizID]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Question %d is not part of this quiz", answer.QuizID)})
+				return
+			}
+			if answered[answer.QuizID] {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Question %d has already been answered", answer.QuizID)})
+				return
+			}
+			answered[answer.QuizID] = true
+			if correct[i], err = gradeAnswer(question, answer); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Question %d: %v", answer.QuizID, err)})
+				return
+			}
+		}
+
+		// Written answers are marked against their rubric by the provider
+		grades := make([]*services.Grade, len(req.Answers))
+		for i, answer := range req.Answers {
+			question := questions[answer.QuizID]
+			if !services.IsWritten(question.Type) {
+				continue
+			}
+			grade, err := services.GradeWrittenAnswer(c.Request.Context(), services.WrittenAnswer{
+				Question:  question.Question,
+				Reference: question.AnswerText,
+				Rubric:    question.Rubric,
+				Answer:    answer.Text,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grade answers"})
+				return
+			}
+			grades[i], correct[i] = &grade, grade.Correct
+		}
+
+		tx, err := database.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answers"})
+			return
+		}
+		defer tx.Rollback()
+
+		for i, answer := range req.Answers {
+			var selections []int64
+			for _, selection := range answer.Selections {
+				selections = append(selections, int64(selection))
+			}
+			var score *int
+			var feedback string
+			var missingPoints []string
+			if grade := grades[i]; grade != nil {
+				score, feedback, missingPoints = &grade.Score, grade.Feedback, grade.MissingPoints
+			}
+			snapshot, err := json.Marshal(questions[answer.QuizID])
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answers"})
+				return
+			}
+			result, err := tx.Exec(
+				`INSERT INTO quiz_attempt_answers (session_id, quiz_id, question_snapshot, selected, selections, text_answer, correct, score, feedback, missing_points)
+				 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''), $10)
+				 ON CONFLICT (session_id, quiz_id) DO NOTHING`,
+				session.ID, answer.QuizID, snapshot, answer.Selected, pq.Array(selections), strings.TrimSpace(answer.Text), correct[i],
+				score, feedback, pq.Array(missingPoints),
+			)
+			if err != nil {
+				fmt.Printf("Failed to save answer for session %d: %v\n", session.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answers"})
+				return
+			}
+			// Another request answered it since the check above
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Question %d has already been answered", answer.QuizID)})
+				return
+			}
+		}
+
+		graded, err := gradeSession(tx, session, questions)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			fmt.Printf("Failed to grade session %d: %v\n", session.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grade session"})
+			return
+		}
+		completePlanTasks(database, graded.Session)
+
+		c.JSON(http.StatusOK, graded)
+	}
+}
+
+// gradeAnswer checks that an answer fits the question's type and reports
+// whether it is correct. Written answers are only checked here, since they
+// are marked by the provider.
+func gradeAnswer(question db.Quiz, answer QuizAnswer) (bool, error) {
+	switch question.Type {
+	case services.QuestionMultiSelect:
+		if len(answer.Selections) == 0 {
+			return false, fmt.Errorf("multi-select answers need selections")
+		}
+		chosen := map[int64]bool{}
+		for _, selection := range answer.Selections {
+			if selection < 0 || selection >= len(question.Options) {
+				return false, fmt.Errorf("selected option %d is out of range", selection)
+			}
+			chosen[int64(selection)] = true
+		}
+		if len(chosen) != len(answer.Selections) {
+			return false, fmt.Errorf("an option is selected more than once")
+		}
+		if len(chosen) != len(question.Answers) {
+			return false, nil
+		}
+		for _, correct := range question.Answers {
+			if !chosen[correct] {
+				return false, nil
+			}
+		}
+		return true, nil
+	case services.QuestionCloze, services.QuestionShortAnswer, services.QuestionEssay:
+		if strings.TrimSpace(answer.Text) == "" {
+			return false, fmt.Errorf("%s answers need text", strings.ReplaceAll(question.Type, "_", " "))
+		}
+		if question.Type == services.QuestionCloze {
+			return services.MatchesAnswer(question.AnswerText, answer.Text), nil
+		}
+		return false, nil
+	default:
+		if answer.Selected == nil {
+			return false, fmt.Errorf("answer needs a selected option")
+		}
+		if *answer.Selected < 0 || *answer.Selected >= len(question.Options) {
+			return false, fmt.Errorf("selected option is out of range")
+		}
+		return *answer.Selected == question.Answer, nil
+	}
+}
+
+// gradeSession scores a quiz session from its stored answers and saves the
+// score. Written answers count for their mark and the rest for all or
+// nothing.
+func gradeSession(tx *sql.Tx, session db.StudySession, questions map[int]db.Quiz) (GradedSession, error) {
+	graded, err := loadGradedAnswers(tx, session, questions)
+	if err != nil {
+		return graded, err
+	}
+
+	points := 0
+	for _, answer := range graded.Answers {
+		if answer.Score != nil {
+			points += *answer.Score
+		} else if answer.Correct {
+			points += 100
+		}
+	}
+	score := 0
+	if graded.Total > 0 {
+		score = (points + graded.Total/2) / graded.Total
+	}
+	graded.Session, err = scanSession(tx.QueryRow(
+		"UPDATE study_sessions SET score = $1, completed = $2 WHERE id = $3 RETURNING "+sessionColumns,
+		score, len(graded.Answers) >= graded.Total, session.ID,
+	))
+	if graded.Session.Completed {
+		graded.Quiz = quizList(questions)
+	}
+	return graded, err
+}
+
+// answeredQuestions fetches the IDs of the questions answered in a session
+func answeredQuestions(database *sql.DB, sessionID int) (map[int]bool, error) {
+	rows, err := database.Query("SELECT quiz_id FROM quiz_attempt_answers WHERE session_id = $1 AND quiz_id IS NOT NULL", sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answered := map[int]bool{}
+	for rows.Next() {
+		var quizID int
+		if err := rows.Scan(&quizID); err != nil {
+			return nil, err
+		}
+		answered[quizID] = true
+	}
+	return answered, rows.Err()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadGradedAnswers fetches the answers submitted in a session, with the
+// questions as they were when answered
+func loadGradedAnswers(q queryer, session db.StudySession, questions map[int]db.Quiz) (GradedSession, error) {
+	graded := GradedSession{Session: session, Answers: []db.QuizAttemptAnswer{}, Total: len(questions)}
+
+	rows, err := q.Query(
+		`SELECT id, session_id, COALESCE(quiz_id, 0), question_snapshot, selected, selections, COALESCE(text_answer, ''), correct, score, COALESCE(feedback, ''), missing_points, created_at
+		 FROM quiz_attempt_answers WHERE session_id = $1 ORDER BY created_at, id`,
+		session.ID,
+	)
+	if err != nil {
+		return graded, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var answer db.QuizAttemptAnswer
+		var snapshot []byte
+		if err := rows.Scan(&answer.ID, &answer.SessionID, &answer.QuizID, &snapshot, &answer.Selected, pq.Array(&answer.Selections), &answer.TextAnswer, &answer.Correct, &answer.Score, &answer.Feedback, pq.Array(&answer.MissingPoints), &answer.CreatedAt); err != nil {
+			return graded, err
+		}
+		// Answers from before snapshots were kept fall back to the quiz
+		question := questions[answer.QuizID]
+		if snapshot != nil {
+			if err := json.Unmarshal(snapshot, &question); err != nil {
+				return graded, fmt.Errorf("failed to read question of answer %d: %w", answer.ID, err)
+			}
+		}
+		answer.Question = question.Question
+		answer.Answer = question.Answer
+		answer.Answers = question.Answers
+		answer.AnswerText = question.AnswerText
+		answer.Explanation = question.Explanation
+		if answer.Correct {
+			graded.Correct++
+		}
+		graded.Answers = append(graded.Answers, answer)
+	}
+
+	return graded, rows.Err()
+}
+
+// GetSession godoc
+// @Summary Get quiz session results
+// @Description Get a study session with the answers graded so far. Correct options are only revealed for submitted questions until the session is completed, when the whole answer key is included. A session whose quiz was regenerated before it was completed is marked stale; its answers are kept, but it can't be finished.
+// @Tags Study Materials
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Study session ID"
+// @Success 200 {object} GradedSession "Session with graded answers"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Study session not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/sessions/{id} [get]
+func getStudySession(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		session, err := scanSession(database.QueryRow(
+			"SELECT "+sessionColumns+" FROM study_sessions WHERE id = $1 AND user_id = $2",
+			c.Param("id"), userID,
+		))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study session not found"})
+			return
+		}
+
+		questions, err := loadSessionQuestions(database, session)
+		stale := errors.Is(err, errStaleSession)
+		if err != nil && !stale {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quiz"})
+			return
+		}
+
+		graded, err := loadGradedAnswers(database, session, questions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch answers"})
+			return
+		}
+		if stale {
+			graded.Stale, graded.Total = true, len(session.QuizIDs)
+		}
+		if session.Completed {
+			graded.Quiz = quizList(questions)
+		}
+
+		c.JSON(http.StatusOK, graded)
+	}
+}
+
+// hideAnswers strips the answer key from quiz questions
+func hideAnswers(quiz []db.Quiz) []QuizQuestion {
+	questions := make([]QuizQuestion, 0, len(quiz))
+	for _, q := range quiz {
+		questions = append(questions, QuizQuestion{
+			ID:        q.ID,
+			NoteID:    q.NoteID,
+			Type:      q.Type,
+			Question:  q.Question,
+			Options:   q.Options,
+			CreatedAt: q.CreatedAt,
+		})
+	}
+	return questions
+}
+
+// quizList returns quiz questions in the order they were created
+func quizList(questions map[int]db.Quiz) []db.Quiz {
+	quiz := make([]db.Quiz, 0, len(questions))
+	for _, q := range questions {
+		quiz = append(quiz, q)
+	}
+	sort.Slice(quiz, func(i, j int) bool {
+		if !quiz[i].CreatedAt.Equal(quiz[j].CreatedAt) {
+			return quiz[i].CreatedAt.Before(quiz[j].CreatedAt)
+		}
+		return quiz[i].ID < quiz[j].ID
+	})
+	return quiz
+}
+
+// loadSessionQuestions fetches the questions of a quiz session: those it
+// started with, or the quiz of the session's note for sessions from before
+// they were kept. If some of them have since been deleted it returns the
+// rest with errStaleSession.
+func loadSessionQuestions(database *sql.DB, session db.StudySession) (map[int]db.Quiz, error) {
+	if session.QuizIDs == nil {
+		return loadQuizQuestions(database, session.NoteID)
+	}
+
+	rows, err := database.Query(
+		"SELECT "+quizColumns+" FROM quizzes WHERE id = ANY($1)",
+		pq.Array(session.QuizIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := map[int]db.Quiz{}
+	for rows.Next() {
+		quiz, err := scanQuiz(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions[quiz.ID] = quiz
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range session.QuizIDs {
+		if _, ok := questions[int(id)]; !ok {
+			return questions, errStaleSession
+		}
+	}
+	return questions, nil
+}
+
+// loadQuizQuestions fetches a note's quiz questions keyed by ID
+func loadQuizQuestions(database *sql.DB, noteID int) (map[int]db.Quiz, error) {
+	rows, err := database.Query(
+		"SELECT "+quizColumns+" FROM quizzes WHERE note_id = $1",
+		noteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := map[int]db.Quiz{}
+	for rows.Next() {
+		quiz, err := scanQuiz(rows)
+		if err != nil {
+			return nil, err
+		}
+		questions[quiz.ID] = quiz
+	}
+	return questions, rows.Err()
+}
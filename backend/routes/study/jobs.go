@@ -0,0 +1,192 @@
+package study
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"studypartner/db"
+	"studypartner/queue"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Background job types for generation
+const (
+	jobTypeSummary    = "summary"
+	jobTypeFlashcards = "flashcards"
+	jobTypeQuiz       = "quiz"
+	jobTypeGlossary   = "glossary"
+	jobTypeConcepts   = "concepts"
+)
+
+// RegisterJobs sets the queue handlers for generation jobs. Call it before
+// starting the workers, so jobs left queued from a previous run can be
+// picked up straight away.
+func RegisterJobs() {
+	queue.Register(jobTypeSummary, runSummaryJob)
+	queue.Register(jobTypeFlashcards, runFlashcardsJob)
+	queue.Register(jobTypeQuiz, runQuizJob)
+	queue.Register(jobTypeGlossary, runGlossaryJob)
+	queue.Register(jobTypeConcepts, runConceptsJob)
+}
+
+// enqueueJob queues generation for a note and replies 202 with the job.
+// payload holds the generation options and may be nil.
+func enqueueJob(c *gin.Context, database *sql.DB, noteID int, jobType string, payload interface{}) {
+	userID, _ := c.Get("userID")
+
+	job, err := queue.Enqueue(database, userID.(int), noteID, jobType, payload)
+	if err != nil {
+		fmt.Printf("Failed to queue %s job for note %d: %v\n", jobType, noteID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/jobs/%d", job.ID))
+	c.JSON(http.StatusAccepted, job)
+}
+
+func runSummaryJob(ctx context.Context, database *sql.DB, job db.Job, progress queue.ProgressFunc) (interface{}, error) {
+	content, err := jobNoteContent(ctx, database, job)
+	if err != nil {
+		return nil, err
+	}
+	progress(10)
+
+	var opts services.SummaryOptions
+	if err := jobOptions(job, &opts); err != nil {
+		return nil, err
+	}
+	opts = opts.WithDefaults()
+
+	summaryContent, err := services.GenerateSummary(ctx, content, opts)
+	if err != nil {
+		return nil, err
+	}
+	progress(90)
+
+	return saveSummary(database, &job, strconv.Itoa(job.NoteID), opts, summaryContent)
+}
+
+func runFlashcardsJob(ctx context.Context, database *sql.DB, job db.Job, progress queue.ProgressFunc) (interface{}, error) {
+	content, err := jobNoteContent(ctx, database, job)
+	if err != nil {
+		return nil, err
+	}
+	progress(10)
+
+	var opts services.GenerationOptions
+	if err := jobOptions(job, &opts); err != nil {
+		return nil, err
+	}
+
+	// Count cards as they stream in; a typical run produces about eight
+	expected := 8
+	if opts.Count > 0 {
+		expected = opts.Count
+	}
+	cards := 0
+	flashcards, err := services.StreamFlashcards(ctx, content, opts, func(services.FlashcardData) error {
+		cards++
+		progress(min(10+cards*80/expected, 90))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	progress(90)
+
+	return replaceFlashcards(database, &job, strconv.Itoa(job.NoteID), flashcards)
+}
+
+func runQuizJob(ctx context.Context, database *sql.DB, job db.Job, progress queue.ProgressFunc) (interface{}, error) {
+	content, err := jobNoteContent(ctx, database, job)
+	if err != nil {
+		return nil, err
+	}
+	progress(10)
+
+	var opts services.QuizOptions
+	if err := jobOptions(job, &opts); err != nil {
+		return nil, err
+	}
+
+	quizQuestions, err := services.GenerateQuiz(ctx, content, opts)
+	if err != nil {
+		return nil, err
+	}
+	progress(90)
+
+	return replaceQuiz(database, &job, strconv.Itoa(job.NoteID), quizQuestions)
+}
+
+func runGlossaryJob(ctx context.Context, database *sql.DB, job db.Job, progress queue.ProgressFunc) (interface{}, error) {
+	content, err := jobNoteContent(ctx, database, job)
+	if err != nil {
+		return nil, err
+	}
+	progress(10)
+
+	terms, err := services.ExtractGlossary(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	progress(90)
+
+	return replaceTerms(database, &job, strconv.Itoa(job.NoteID), terms)
+}
+
+func runConceptsJob(ctx context.Context, database *sql.DB, job db.Job, progress queue.ProgressFunc) (interface{}, error) {
+	content, err := jobNoteContent(ctx, database, job)
+	if err != nil {
+		return nil, err
+	}
+	progress(10)
+
+	graph, err := services.ExtractConcepts(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	progress(90)
+
+	return replaceNoteConcepts(database, &job, job.UserID, job.NoteID, graph)
+}
+
+// lockJob holds the job a save belongs to for the rest of tx; see
+// queue.Lock. job is nil for output generated during the request.
+func lockJob(tx *sql.Tx, job *db.Job) error {
+	if job == nil {
+		return nil
+	}
+	return queue.Lock(tx, *job)
+}
+
+// jobOptions decodes the generation options a job was queued with
+func jobOptions(job db.Job, opts interface{}) error {
+	if len(job.Payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(job.Payload, opts); err != nil {
+		return fmt.Errorf("invalid job options: %w", err)
+	}
+	return nil
+}
+
+// jobNoteContent loads the note a job works on, as long as it still belongs
+// to the user who queued it
+func jobNoteContent(ctx context.Context, database *sql.DB, job db.Job) (string, error) {
+	var content string
+	err := database.QueryRowContext(ctx,
+		"SELECT content FROM notes WHERE id = $1 AND user_id = $2",
+		job.NoteID, job.UserID,
+	).Scan(&content)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("note %d not found", job.NoteID)
+	}
+	return content, err
+}
@@ -0,0 +1,614 @@
+package study
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"studypartner/db"
+	"studypartner/planner"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Study plan column lists read by scanStudyPlan and scanPlanTask
+const (
+	studyPlanColumns = "id, user_id, exam_date, note_ids, created_at, updated_at"
+	planTaskColumns  = "id, plan_id, note_id, day, type, minutes, completed, session_id, completed_at"
+)
+
+// maxPlanNotes is the most notes a study plan can cover
+const maxPlanNotes = 50
+
+// planDateLayout is how plan dates are written in requests and queries
+const planDateLayout = "2006-01-02"
+
+// sessionTaskTypes maps study session types to the plan tasks they complete
+var sessionTaskTypes = map[string]string{
+	"summary":    planner.TaskRead,
+	"flashcard":  planner.TaskFlashcards,
+	"flashcards": planner.TaskFlashcards,
+	"quiz":       planner.TaskQuiz,
+}
+
+// StudyPlanRequest is the body of a request to create or change a study plan
+type StudyPlanRequest struct {
+	NoteIDs  []int64 `json:"note_ids"`
+	ExamDate string  `json:"exam_date" example:"2026-12-01"` // YYYY-MM-DD
+}
+
+// CreateStudyPlan godoc
+// @Summary Create a study plan
+// @Description Lay out a day-by-day schedule leading up to an exam: reading each note, reviewing its flashcards and taking its quiz, spread so that every day has a similar amount of work. Notes with a weak quiz score get an extra review and quiz.
+// @Tags Study Plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body StudyPlanRequest true "Notes to cover and the exam date"
+// @Success 201 {object} db.StudyPlan "Study plan with its tasks"
+// @Failure 400 {object} map[string]string "Invalid notes or exam date"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/plans [post]
+func createStudyPlan(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req StudyPlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.ExamDate == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exam_date is required"})
+			return
+		}
+
+		plan := db.StudyPlan{UserID: userID.(int)}
+		if !applyPlanRequest(c, database, &plan, req) {
+			return
+		}
+
+		// The plan is only kept if its tasks can be scheduled
+		tx, err := database.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study plan"})
+			return
+		}
+		defer tx.Rollback()
+
+		err = tx.QueryRow(
+			"INSERT INTO study_plans (user_id, exam_date, note_ids) VALUES ($1, $2, $3) RETURNING id",
+			plan.UserID, plan.ExamDate.Format(planDateLayout), pq.Array(plan.NoteIDs),
+		).Scan(&plan.ID)
+		if err != nil {
+			fmt.Printf("Failed to create study plan: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study plan"})
+			return
+		}
+
+		err = schedulePlanTasks(tx, plan)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if errors.Is(err, planner.ErrExamPassed) || errors.Is(err, planner.ErrTooFar) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			fmt.Printf("Failed to schedule study plan %d: %v\n", plan.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule study plan"})
+			return
+		}
+
+		plan, err = loadStudyPlan(database, plan.UserID, plan.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study plan"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, plan)
+	}
+}
+
+// ListStudyPlans godoc
+// @Summary List study plans
+// @Description List the user's study plans, nearest exam first, without their tasks
+// @Tags Study Plans
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} db.StudyPlan "Study plans"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/plans [get]
+func listStudyPlans(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		rows, err := database.Query(
+			"SELECT "+studyPlanColumns+" FROM study_plans WHERE user_id = $1 ORDER BY exam_date, id",
+			userID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study plans"})
+			return
+		}
+		defer rows.Close()
+
+		plans := []db.StudyPlan{}
+		for rows.Next() {
+			plan, err := scanStudyPlan(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan study plan"})
+				return
+			}
+			plans = append(plans, plan)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study plans"})
+			return
+		}
+
+		c.JSON(http.StatusOK, plans)
+	}
+}
+
+// GetStudyPlan godoc
+// @Summary Get a study plan
+// @Description Get a study plan with its tasks by day. behind counts the tasks left undone on days already past; updating the plan or completing a session reschedules them.
+// @Tags Study Plans
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Study plan ID"
+// @Success 200 {object} db.StudyPlan "Study plan with its tasks"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Study plan not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/plans/{id} [get]
+func getStudyPlan(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		plan, err := loadStudyPlan(database, userID.(int), c.Param("id"))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study plan not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study plan"})
+			return
+		}
+
+		c.JSON(http.StatusOK, plan)
+	}
+}
+
+// UpdateStudyPlan godoc
+// @Summary Update a study plan
+// @Description Change a plan's notes or exam date, and reschedule the work left on it from today. Completed tasks are kept; with an empty body the plan is only rescheduled, which catches up after falling behind.
+// @Tags Study Plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Study plan ID"
+// @Param request body StudyPlanRequest false "New notes or exam date"
+// @Success 200 {object} db.StudyPlan "Rescheduled study plan"
+// @Failure 400 {object} map[string]string "Invalid notes or exam date"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Study plan or note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/plans/{id} [put]
+func updateStudyPlan(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		// The body is optional: without one the plan is only rescheduled
+		var req StudyPlanRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		plan, err := loadStudyPlan(database, userID.(int), c.Param("id"))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study plan not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study plan"})
+			return
+		}
+		if !applyPlanRequest(c, database, &plan, req) {
+			return
+		}
+
+		// The changes are only kept if the plan can be rescheduled
+		tx, err := database.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule study plan"})
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(
+			"UPDATE study_plans SET exam_date = $1, note_ids = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
+			plan.ExamDate.Format(planDateLayout), pq.Array(plan.NoteIDs), plan.ID,
+		)
+		if err == nil {
+			err = schedulePlanTasks(tx, plan)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if errors.Is(err, planner.ErrExamPassed) || errors.Is(err, planner.ErrTooFar) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			fmt.Printf("Failed to reschedule study plan %d: %v\n", plan.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reschedule study plan"})
+			return
+		}
+
+		plan, err = loadStudyPlan(database, plan.UserID, plan.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch study plan"})
+			return
+		}
+
+		c.JSON(http.StatusOK, plan)
+	}
+}
+
+// UpdatePlanTask godoc
+// @Summary Mark a study plan task
+// @Description Mark a task of a study plan done or not done, for work done outside a study session. Tasks are also completed by the study sessions that do them.
+// @Tags Study Plans
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Study plan ID"
+// @Param taskId path int true "Task ID"
+// @Param request body object{completed=bool} true "Whether the task is done"
+// @Success 200 {object} db.StudyPlanTask "Updated task"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Task not found"
+// @Router /study/plans/{id}/tasks/{taskId} [put]
+func updatePlanTask(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req struct {
+			Completed bool `json:"completed"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		task, err := scanPlanTask(database.QueryRow(
+			`UPDATE study_plan_tasks t SET completed = $1,
+			   completed_at = CASE WHEN $1 THEN COALESCE(t.completed_at, CURRENT_TIMESTAMP) END,
+			   session_id = CASE WHEN $1 THEN t.session_id END
+			 FROM study_plans p
+			 WHERE t.id = $2 AND t.plan_id = $3 AND p.id = t.plan_id AND p.user_id = $4
+			 RETURNING t.id, t.plan_id, t.note_id, t.day, t.type, t.minutes, t.completed, t.session_id, t.completed_at`,
+			req.Completed, c.Param("taskId"), c.Param("id"), userID,
+		))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// DeleteStudyPlan godoc
+// @Summary Delete a study plan
+// @Description Delete a study plan and its tasks
+// @Tags Study Plans
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Study plan ID"
+// @Success 200 {object} map[string]string "Study plan deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Study plan not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/plans/{id} [delete]
+func deleteStudyPlan(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		result, err := database.Exec("DELETE FROM study_plans WHERE id = $1 AND user_id = $2", c.Param("id"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete study plan"})
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Study plan not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Study plan deleted successfully"})
+	}
+}
+
+// applyPlanRequest checks the notes and exam date of a request and sets
+// them on the plan, keeping the plan's own where the request leaves them
+// out. It replies with the error and returns false when they are invalid.
+func applyPlanRequest(c *gin.Context, database *sql.DB, plan *db.StudyPlan, req StudyPlanRequest) bool {
+	if req.ExamDate != "" {
+		exam, err := time.Parse(planDateLayout, req.ExamDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exam_date must be a date like 2026-12-01"})
+			return false
+		}
+		today := planner.Day(time.Now())
+		if !exam.After(today) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": planner.ErrExamPassed.Error()})
+			return false
+		}
+		if exam.After(today.AddDate(0, 0, planner.MaxDays)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": planner.ErrTooFar.Error()})
+			return false
+		}
+		plan.ExamDate = exam
+	}
+
+	if req.NoteIDs == nil {
+		if plan.ID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "note_ids is required"})
+			return false
+		}
+		return true
+	}
+
+	seen := map[int64]bool{}
+	noteIDs := []int64{}
+	for _, id := range req.NoteIDs {
+		if !seen[id] {
+			seen[id] = true
+			noteIDs = append(noteIDs, id)
+		}
+	}
+	if len(noteIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note_ids cannot be empty"})
+		return false
+	}
+	if len(noteIDs) > maxPlanNotes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A plan can cover at most %d notes", maxPlanNotes)})
+		return false
+	}
+
+	// Check that the notes belong to the user
+	var owned int
+	err := database.QueryRow(
+		"SELECT COUNT(*) FROM notes WHERE user_id = $1 AND id = ANY($2)",
+		plan.UserID, pq.Array(noteIDs),
+	).Scan(&owned)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return false
+	}
+	if owned != len(noteIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return false
+	}
+
+	plan.NoteIDs = noteIDs
+	return true
+}
+
+// loadStudyPlan reads one of a user's study plans with its tasks, returning
+// sql.ErrNoRows when there is no such plan
+func loadStudyPlan(database *sql.DB, userID int, planID interface{}) (db.StudyPlan, error) {
+	plan, err := scanStudyPlan(database.QueryRow(
+		"SELECT "+studyPlanColumns+" FROM study_plans WHERE id = $1 AND user_id = $2",
+		planID, userID,
+	))
+	if err != nil {
+		return plan, err
+	}
+
+	rows, err := database.Query(
+		"SELECT "+planTaskColumns+" FROM study_plan_tasks WHERE plan_id = $1 ORDER BY day, id",
+		plan.ID,
+	)
+	if err != nil {
+		return plan, err
+	}
+	defer rows.Close()
+
+	today := planner.Day(time.Now())
+	plan.Tasks = []db.StudyPlanTask{}
+	for rows.Next() {
+		task, err := scanPlanTask(rows)
+		if err != nil {
+			return plan, err
+		}
+		if !task.Completed && task.Day.Before(today) {
+			plan.Behind++
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+	return plan, rows.Err()
+}
+
+// replanStudyPlan schedules the work left on a plan from today, in place of
+// its incomplete tasks
+func replanStudyPlan(database *sql.DB, plan db.StudyPlan) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := schedulePlanTasks(tx, plan); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schedulePlanTasks replaces a plan's incomplete tasks with the work left on
+// it from today. The work is worked out afresh from the notes: the
+// flashcards due before the exam, the quiz and its latest score, and the
+// tasks already completed.
+func schedulePlanTasks(tx *sql.Tx, plan db.StudyPlan) error {
+	rows, err := tx.Query(
+		`SELECT n.id,
+		   COALESCE(array_length(regexp_split_to_array(btrim(n.content), '\s+'), 1), 0),
+		   (SELECT COUNT(*) FROM flashcards f
+		    LEFT JOIN flashcard_reviews r ON r.flashcard_id = f.id AND r.user_id = $1
+		    WHERE f.note_id = n.id AND (r.id IS NULL OR r.due_at < $3)),
+		   (SELECT COUNT(*) FROM quizzes q WHERE q.note_id = n.id),
+		   (SELECT s.score FROM study_sessions s
+		    WHERE s.note_id = n.id AND s.user_id = $1 AND s.type = 'quiz' AND s.completed
+		    ORDER BY s.created_at DESC LIMIT 1)
+		 FROM notes n WHERE n.user_id = $1 AND n.id = ANY($2::int[])
+		 ORDER BY array_position($2::int[], n.id)`,
+		plan.UserID, pq.Array(plan.NoteIDs), plan.ExamDate,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var notes []planner.Note
+	index := map[int]int{}
+	for rows.Next() {
+		var note planner.Note
+		if err := rows.Scan(&note.ID, &note.Words, &note.Flashcards, &note.Questions, &note.QuizScore); err != nil {
+			return err
+		}
+		index[note.ID] = len(notes)
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(
+		"SELECT note_id, type, COUNT(*) FROM study_plan_tasks WHERE plan_id = $1 AND completed GROUP BY note_id, type",
+		plan.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var noteID, count int
+		var taskType string
+		if err := rows.Scan(&noteID, &taskType, &count); err != nil {
+			return err
+		}
+		i, ok := index[noteID]
+		if !ok {
+			continue
+		}
+		switch taskType {
+		case planner.TaskRead:
+			notes[i].Read = true
+		case planner.TaskFlashcards:
+			notes[i].FlashcardsDone = count
+		case planner.TaskQuiz:
+			notes[i].QuizzesDone = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tasks, err := planner.Schedule(notes, time.Now(), plan.ExamDate)
+	if err != nil {
+		return err
+	}
+
+	// Drop the incomplete tasks, and any completed ones on notes the plan
+	// no longer covers
+	if _, err := tx.Exec(
+		"DELETE FROM study_plan_tasks WHERE plan_id = $1 AND (NOT completed OR note_id <> ALL($2))",
+		plan.ID, pq.Array(plan.NoteIDs),
+	); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if _, err := tx.Exec(
+			"INSERT INTO study_plan_tasks (plan_id, note_id, day, type, minutes) VALUES ($1, $2, $3, $4, $5)",
+			plan.ID, task.NoteID, task.Day.Format(planDateLayout), task.Type, task.Minutes,
+		); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE study_plans SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", plan.ID)
+	return err
+}
+
+// completePlanTasks marks the earliest matching task of each of the user's
+// upcoming plans done by a completed study session. A plan is rescheduled
+// when it has fallen behind, or when a weak quiz score calls for more work
+// on the note. Failures are only logged, as the session itself is saved.
+func completePlanTasks(database *sql.DB, session db.StudySession) {
+	taskType, ok := sessionTaskTypes[session.Type]
+	if !ok || !session.Completed {
+		return
+	}
+
+	today := planner.Day(time.Now())
+	rows, err := database.Query(
+		`UPDATE study_plan_tasks SET completed = TRUE, session_id = $1, completed_at = CURRENT_TIMESTAMP
+		 WHERE id IN (
+		   SELECT DISTINCT ON (t.plan_id) t.id
+		   FROM study_plan_tasks t JOIN study_plans p ON p.id = t.plan_id
+		   WHERE p.user_id = $2 AND p.exam_date > $3 AND t.note_id = $4 AND t.type = $5 AND NOT t.completed
+		     AND NOT EXISTS (SELECT 1 FROM study_plan_tasks d WHERE d.plan_id = t.plan_id AND d.session_id = $1)
+		   ORDER BY t.plan_id, t.day, t.id)
+		 RETURNING plan_id`,
+		session.ID, session.UserID, today.Format(planDateLayout), session.NoteID, taskType,
+	)
+	if err != nil {
+		fmt.Printf("Failed to complete plan tasks for session %d: %v\n", session.ID, err)
+		return
+	}
+	var planIDs []int
+	for rows.Next() {
+		var planID int
+		if err := rows.Scan(&planID); err != nil {
+			fmt.Printf("Failed to complete plan tasks for session %d: %v\n", session.ID, err)
+			break
+		}
+		planIDs = append(planIDs, planID)
+	}
+	rows.Close()
+
+	weak := taskType == planner.TaskQuiz && session.Score != nil && *session.Score < planner.WeakScore
+	for _, planID := range planIDs {
+		plan, err := loadStudyPlan(database, session.UserID, planID)
+		if err == nil && (plan.Behind > 0 || weak) {
+			err = replanStudyPlan(database, plan)
+		}
+		if err != nil {
+			fmt.Printf("Failed to reschedule study plan %d: %v\n", planID, err)
+		}
+	}
+}
+
+// scanStudyPlan reads a row of studyPlanColumns
+func scanStudyPlan(row rowScanner) (db.StudyPlan, error) {
+	var plan db.StudyPlan
+	err := row.Scan(&plan.ID, &plan.UserID, &plan.ExamDate, pq.Array(&plan.NoteIDs), &plan.CreatedAt, &plan.UpdatedAt)
+	return plan, err
+}
+
+// scanPlanTask reads a row of planTaskColumns
+func scanPlanTask(row rowScanner) (db.StudyPlanTask, error) {
+	var task db.StudyPlanTask
+	err := row.Scan(&task.ID, &task.PlanID, &task.NoteID, &task.Day, &task.Type, &task.Minutes, &task.Completed, &task.SessionID, &task.CompletedAt)
+	return task, err
+}
@@ -0,0 +1,282 @@
+package study
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"studypartner/db"
+	"studypartner/srs"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewRequest struct {
+	Grade string `json:"grade" binding:"required"` // "again", "hard", "good" or "easy"
+}
+
+// ReviewFlashcard godoc
+// @Summary Review a flashcard
+// @Description Record how well the user recalled a flashcard and schedule its next review with SM-2
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Flashcard ID"
+// @Param request body ReviewRequest true "Recall grade"
+// @Success 200 {object} db.FlashcardReview "Updated schedule"
+// @Failure 400 {object} map[string]string "Invalid grade"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Flashcard not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/flashcards/{id}/review [post]
+func reviewFlashcard(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		flashcardID := c.Param("id")
+
+		var req ReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		grade, err := srs.ParseGrade(req.Grade)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Grade must be one of again, hard, good or easy"})
+			return
+		}
+
+		// Check if the flashcard belongs to one of the user's notes
+		var flashcard db.Flashcard
+		err = database.QueryRow(
+			"SELECT f.id FROM flashcards f JOIN notes n ON n.id = f.note_id WHERE f.id = $1 AND n.user_id = $2",
+			flashcardID, userID,
+		).Scan(&flashcard.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Flashcard not found"})
+			return
+		}
+
+		// Times are stored in UTC, so schedules don't depend on the server
+		// zone. Every review timestamp comes from this clock rather than the
+		// database's, so the queue compares like with like.
+		now := time.Now().UTC()
+		state := srs.NewState(now)
+		err = database.QueryRow(
+			"SELECT ease, interval_days, repetitions, lapses, due_at, last_reviewed_at FROM flashcard_reviews WHERE user_id = $1 AND flashcard_id = $2",
+			userID, flashcard.ID,
+		).Scan(&state.Ease, &state.Interval, &state.Repetitions, &state.Lapses, &state.Due, &state.LastReviewed)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review state"})
+			return
+		}
+
+		state = srs.Review(state, grade, now)
+
+		var review db.FlashcardReview
+		err = database.QueryRow(
+			`INSERT INTO flashcard_reviews (user_id, flashcard_id, ease, interval_days, repetitions, lapses, due_at, last_reviewed_at, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $8)
+			 ON CONFLICT (user_id, flashcard_id) DO UPDATE SET
+			   ease = $3, interval_days = $4, repetitions = $5, lapses = $6, due_at = $7, last_reviewed_at = $8, updated_at = $8
+			 RETURNING id, user_id, flashcard_id, ease, interval_days, repetitions, lapses, due_at, last_reviewed_at, created_at, updated_at`,
+			userID, flashcard.ID, state.Ease, state.Interval, state.Repetitions, state.Lapses, state.Due, state.LastReviewed,
+		).Scan(&review.ID, &review.UserID, &review.FlashcardID, &review.Ease, &review.IntervalDays, &review.Repetitions, &review.Lapses, &review.DueAt, &review.LastReviewedAt, &review.CreatedAt, &review.UpdatedAt)
+		if err != nil {
+			fmt.Printf("Failed to save review of flashcard %d: %v\n", flashcard.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+			return
+		}
+
+		c.JSON(http.StatusOK, review)
+	}
+}
+
+// Default daily limits for the review queue
+const (
+	defaultNewPerDay    = 20
+	defaultReviewPerDay = 200
+)
+
+// ReviewCard is a flashcard in the review queue. Review is nil for a card
+// the user has never studied.
+type ReviewCard struct {
+	db.Flashcard
+	NoteTitle string              `json:"note_title"`
+	New       bool                `json:"new"`
+	Review    *db.FlashcardReview `json:"review,omitempty"`
+}
+
+type ReviewQueue struct {
+	Cards           []ReviewCard `json:"cards"`            // Due reviews first, then new cards
+	DueCount        int          `json:"due_count"`        // All due cards, before the daily limit
+	NewCount        int          `json:"new_count"`        // All unstudied cards, before the daily limit
+	ReviewsLeft     int          `json:"reviews_left"`     // Reviews still allowed today
+	NewLeft         int          `json:"new_left"`         // New cards still allowed today
+	ReviewedToday   int          `json:"reviewed_today"`   // Reviews of previously studied cards today
+	IntroducedToday int          `json:"introduced_today"` // Cards studied for the first time today
+}
+
+// GetReviewQueue godoc
+// @Summary Get the review queue
+// @Description Flashcards due for review across all the user's notes, most overdue first relative to their interval, followed by new cards. Daily limits are counted from midnight UTC.
+// @Tags Study Materials
+// @Produce json
+// @Security BearerAuth
+// @Param note_id query int false "Only cards from this note"
+// @Param tag query string false "Only cards from notes with this tag"
+// @Param new_limit query int false "New cards per day (default 20)"
+// @Param review_limit query int false "Reviews per day (default 200)"
+// @Success 200 {object} ReviewQueue "Cards to study now"
+// @Failure 400 {object} map[string]string "Invalid limit"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/review-queue [get]
+func getReviewQueue(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Query("note_id")
+		tag := strings.ToLower(strings.TrimSpace(c.Query("tag")))
+
+		newLimit, err := queryLimit(c, "new_limit", defaultNewPerDay)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reviewLimit, err := queryLimit(c, "review_limit", defaultReviewPerDay)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The same clock as the reviews; see reviewFlashcard
+		now := time.Now().UTC()
+		today := now.Truncate(24 * time.Hour)
+
+		// What has already been studied today counts against the limits
+		queue := ReviewQueue{Cards: []ReviewCard{}}
+		err = database.QueryRow(
+			`SELECT COUNT(*) FILTER (WHERE created_at >= $2),
+			        COUNT(*) FILTER (WHERE created_at < $2 AND last_reviewed_at >= $2)
+			 FROM flashcard_reviews WHERE user_id = $1`,
+			userID, today,
+		).Scan(&queue.IntroducedToday, &queue.ReviewedToday)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count today's reviews"})
+			return
+		}
+		queue.ReviewsLeft = max(reviewLimit-queue.ReviewedToday, 0)
+		queue.NewLeft = max(newLimit-queue.IntroducedToday, 0)
+
+		// Counted apart from the queries below, which stop at the limits
+		err = database.QueryRow(
+			`SELECT
+			   (SELECT COUNT(*) FROM flashcard_reviews r
+			    JOIN flashcards f ON f.id = r.flashcard_id
+			    JOIN notes n ON n.id = f.note_id
+			    WHERE r.user_id = $1 AND n.user_id = $1 AND r.due_at <= $2
+			      AND ($3 = '' OR n.id::text = $3)
+			      AND ($4 = '' OR EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id AND t.tag = $4))),
+			   (SELECT COUNT(*) FROM flashcards f
+			    JOIN notes n ON n.id = f.note_id
+			    WHERE n.user_id = $1
+			      AND NOT EXISTS (SELECT 1 FROM flashcard_reviews r WHERE r.flashcard_id = f.id AND r.user_id = $1)
+			      AND ($3 = '' OR n.id::text = $3)
+			      AND ($4 = '' OR EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id AND t.tag = $4)))`,
+			userID, now, noteID, tag,
+		).Scan(&queue.DueCount, &queue.NewCount)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count cards"})
+			return
+		}
+
+		// Overdue-ness is time past due as a fraction of the interval, so a
+		// card a day late on a two day interval beats one a day late on a month
+		rows, err := database.Query(
+			`SELECT f.id, f.note_id, f.question, f.answer, COALESCE(f.explanation, ''), f.source_chunk_id, f.source_start, f.source_end, f.created_at, n.title,
+			        r.id, r.user_id, r.flashcard_id, r.ease, r.interval_days, r.repetitions, r.lapses, r.due_at, r.last_reviewed_at, r.created_at, r.updated_at
+			 FROM flashcard_reviews r
+			 JOIN flashcards f ON f.id = r.flashcard_id
+			 JOIN notes n ON n.id = f.note_id
+			 WHERE r.user_id = $1 AND n.user_id = $1 AND r.due_at <= $2
+			   AND ($3 = '' OR n.id::text = $3)
+			   AND ($4 = '' OR EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id AND t.tag = $4))
+			 ORDER BY EXTRACT(EPOCH FROM ($2 - r.due_at)) / (GREATEST(r.interval_days, 1) * 86400) DESC, r.due_at, f.id
+			 LIMIT $5`,
+			userID, now, noteID, tag, queue.ReviewsLeft,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch due cards"})
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var card ReviewCard
+			var review db.FlashcardReview
+			err := rows.Scan(&card.ID, &card.NoteID, &card.Question, &card.Answer, &card.Explanation, &card.SourceChunkID, &card.SourceStart, &card.SourceEnd, &card.CreatedAt, &card.NoteTitle,
+				&review.ID, &review.UserID, &review.FlashcardID, &review.Ease, &review.IntervalDays, &review.Repetitions, &review.Lapses, &review.DueAt, &review.LastReviewedAt, &review.CreatedAt, &review.UpdatedAt)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan due card"})
+				return
+			}
+			card.Review = &review
+			queue.Cards = append(queue.Cards, card)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch due cards"})
+			return
+		}
+
+		// New cards in the order their notes were added
+		rows, err = database.Query(
+			`SELECT f.id, f.note_id, f.question, f.answer, COALESCE(f.explanation, ''), f.source_chunk_id, f.source_start, f.source_end, f.created_at, n.title
+			 FROM flashcards f
+			 JOIN notes n ON n.id = f.note_id
+			 WHERE n.user_id = $1
+			   AND NOT EXISTS (SELECT 1 FROM flashcard_reviews r WHERE r.flashcard_id = f.id AND r.user_id = $1)
+			   AND ($2 = '' OR n.id::text = $2)
+			   AND ($3 = '' OR EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id AND t.tag = $3))
+			 ORDER BY n.created_at, f.created_at, f.id
+			 LIMIT $4`,
+			userID, noteID, tag, queue.NewLeft,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch new cards"})
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			card := ReviewCard{New: true}
+			err := rows.Scan(&card.ID, &card.NoteID, &card.Question, &card.Answer, &card.Explanation, &card.SourceChunkID, &card.SourceStart, &card.SourceEnd, &card.CreatedAt, &card.NoteTitle)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan new card"})
+				return
+			}
+			queue.Cards = append(queue.Cards, card)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch new cards"})
+			return
+		}
+
+		c.JSON(http.StatusOK, queue)
+	}
+}
+
+// queryLimit reads a non-negative integer query parameter
+func queryLimit(c *gin.Context, name string, defaultValue int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return limit, nil
+}
@@ -0,0 +1,209 @@
+package study
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"studypartner/db"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamSummary godoc
+// @Summary Stream a note summary
+// @Description Generate a note summary as server-sent events. Emits "token" events with {"token": "..."} as text is generated, then "done" with the saved summary, or "error". Takes the same optional body as POST /study/notes/{id}/summary.
+// @Tags Study Materials
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param request body services.SummaryOptions false "Summary options"
+// @Success 200 {object} db.Summary "Event stream ending with the saved summary"
+// @Failure 400 {object} map[string]string "Invalid options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Router /study/notes/{id}/summary/stream [post]
+func streamSummary(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		var opts services.SummaryOptions
+		if err := bindOptions(c, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts = opts.WithDefaults()
+
+		// Check if note belongs to user and get content
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id, content FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID, &note.Content)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		startSSE(c)
+		summaryContent, err := services.StreamSummary(c.Request.Context(), note.Content, opts, func(token string) error {
+			return sendEvent(c, "token", gin.H{"token": token})
+		})
+		if err != nil {
+			streamFailed(c, "Failed to generate summary", err)
+			return
+		}
+
+		summary, err := saveSummary(database, nil, noteID, opts, summaryContent)
+		if err != nil {
+			fmt.Printf("Failed to save summary for note %s: %v\n", noteID, err)
+			sendEvent(c, "error", gin.H{"error": "Failed to save summary"})
+			return
+		}
+
+		sendEvent(c, "done", summary)
+	}
+}
+
+// StreamFlashcards godoc
+// @Summary Stream flashcards
+// @Description Generate flashcards as server-sent events. Emits a "card" event with {"question", "answer", "explanation", "source", "span"} as each card is completed, then "done" with the saved flashcards, or "error". Takes the same optional body as POST /study/notes/{id}/flashcards.
+// @Tags Study Materials
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param request body services.GenerationOptions false "Generation options"
+// @Success 200 {array} db.Flashcard "Event stream ending with the saved flashcards"
+// @Failure 400 {object} map[string]string "Invalid options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Router /study/notes/{id}/flashcards/stream [post]
+func streamFlashcards(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		var opts services.GenerationOptions
+		if err := bindOptions(c, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Check if note belongs to user and get content
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id, content FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID, &note.Content)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		startSSE(c)
+		flashcards, err := services.StreamFlashcards(c.Request.Context(), note.Content, opts, func(card services.FlashcardData) error {
+			return sendEvent(c, "card", card)
+		})
+		if err != nil {
+			streamFailed(c, "Failed to generate flashcards", err)
+			return
+		}
+
+		insertedFlashcards, err := replaceFlashcards(database, nil, noteID, flashcards)
+		if err != nil {
+			fmt.Printf("Failed to save flashcards for note %s: %v\n", noteID, err)
+			sendEvent(c, "error", gin.H{"error": "Failed to save flashcards"})
+			return
+		}
+
+		sendEvent(c, "done", insertedFlashcards)
+	}
+}
+
+// StreamMessage godoc
+// @Summary Send a message to the tutor and stream the reply
+// @Description Like POST /study/conversations/{id}/messages, but the reply arrives as server-sent events: "token" events with {"token": "..."}, then "done" with the saved student message and tutor reply, or "error".
+// @Tags Tutor
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param id path int true "Conversation ID"
+// @Param request body AppendMessageRequest true "Student message"
+// @Success 200 {array} db.ConversationMessage "Event stream ending with the saved messages"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Conversation not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/conversations/{id}/messages/stream [post]
+func streamMessage(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req AppendMessageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		turn, status, err := prepareTutorTurn(c.Request.Context(), database, c.Param("id"), userID.(int), req)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		startSSE(c)
+		reply, err := services.StreamTutorReply(c.Request.Context(), turn.Conversation.Mode, turn.History, turn.Message, turn.Passages, func(token string) error {
+			return sendEvent(c, "token", gin.H{"token": token})
+		})
+		if err != nil {
+			streamFailed(c, "Failed to generate reply", err)
+			return
+		}
+
+		messages, err := saveTutorTurn(c.Request.Context(), database, turn, reply)
+		if err != nil {
+			fmt.Printf("Failed to save messages for conversation %d: %v\n", turn.Conversation.ID, err)
+			sendEvent(c, "error", gin.H{"error": "Failed to save messages"})
+			return
+		}
+
+		sendEvent(c, "done", messages)
+	}
+}
+
+// startSSE switches the response to a server-sent event stream. Errors
+// after this point are reported as "error" events.
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// sendEvent writes one JSON event and flushes it to the client. It fails
+// once the client has disconnected, which stops generation.
+func sendEvent(c *gin.Context, event string, data interface{}) error {
+	if err := c.Request.Context().Err(); err != nil {
+		return err
+	}
+	c.SSEvent(event, data)
+	c.Writer.Flush()
+	return nil
+}
+
+// streamFailed reports a generation error to the client, unless the error
+// is the client going away
+func streamFailed(c *gin.Context, message string, err error) {
+	if c.Request.Context().Err() != nil {
+		fmt.Printf("Client disconnected from %s, generation cancelled\n", c.Request.URL.Path)
+		return
+	}
+	fmt.Printf("%s: %v\n", message, err)
+	sendEvent(c, "error", gin.H{"error": message})
+}
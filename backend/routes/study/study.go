@@ -2,7 +2,9 @@ package study
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -19,25 +21,58 @@ func SetupStudyRoutes(router *gin.RouterGroup, database *sql.DB) {
 	study.Use(middleware.AuthRequired())
 	{
 		study.GET("/notes/:id/summary", getSummary(database))
+		study.GET("/notes/:id/summaries", listSummaries(database))
 		study.POST("/notes/:id/summary", generateSummary(database))
 		study.GET("/notes/:id/flashcards", getFlashcards(database))
 		study.POST("/notes/:id/flashcards", generateFlashcards(database))
+		study.POST("/notes/:id/summary/stream", streamSummary(database))
+		study.POST("/notes/:id/flashcards/stream", streamFlashcards(database))
+		study.POST("/flashcards/:id/review", reviewFlashcard(database))
+		study.GET("/review-queue", getReviewQueue(database))
 		study.GET("/notes/:id/quiz", getQuiz(database))
 		study.POST("/notes/:id/quiz", generateQuiz(database))
+		study.GET("/notes/:id/glossary", getGlossary(database))
+		study.POST("/notes/:id/glossary", generateGlossary(database))
+		study.POST("/notes/:id/glossary/flashcards", glossaryFlashcards(database))
+		study.GET("/notes/:id/concepts", getNoteConcepts(database))
+		study.POST("/notes/:id/concepts", generateConcepts(database))
+		study.GET("/concepts", getConcepts(database))
 		study.POST("/sessions", createStudySession(database))
 		study.PUT("/sessions/:id", updateStudySession(database))
+		study.GET("/sessions/:id", getStudySession(database))
+		study.POST("/sessions/:id/answers", submitAnswers(database))
+		study.POST("/adaptive-quiz", createAdaptiveQuiz(database))
+
+		// Study plans
+		study.POST("/plans", createStudyPlan(database))
+		study.GET("/plans", listStudyPlans(database))
+		study.GET("/plans/:id", getStudyPlan(database))
+		study.PUT("/plans/:id", updateStudyPlan(database))
+		study.PUT("/plans/:id/tasks/:taskId", updatePlanTask(database))
+		study.DELETE("/plans/:id", deleteStudyPlan(database))
+
+		// Tutor conversations
+		study.POST("/notes/:id/conversations", createConversation(database))
+		study.GET("/conversations", listConversations(database))
+		study.GET("/conversations/:id", getConversation(database))
+		study.PUT("/conversations/:id", updateConversation(database))
+		study.POST("/conversations/:id/messages", appendMessage(database))
+		study.POST("/conversations/:id/messages/stream", streamMessage(database))
+		study.DELETE("/conversations/:id", deleteConversation(database))
 	}
 }
 
 // GetSummary godoc
 // @Summary Get note summary
-// @Description Get the AI-generated summary for a specific note
+// @Description Get the AI-generated summary of a note in one style, the standard style by default
 // @Tags Study Materials
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Note ID"
+// @Param style query string false "Summary style: standard, bullets, outline, tldr or cheat_sheet"
 // @Success 200 {object} db.Summary "Note summary"
+// @Failure 400 {object} map[string]string "Invalid style"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Note or summary not found"
 // @Router /study/notes/{id}/summary [get]
@@ -46,6 +81,13 @@ func getSummary(database *sql.DB) gin.HandlerFunc {
 		userID, _ := c.Get("userID")
 		noteID := c.Param("id")
 
+		opts := services.SummaryOptions{Style: c.Query("style")}
+		if err := opts.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts = opts.WithDefaults()
+
 		// Check if note belongs to user
 		var note db.Note
 		err := database.QueryRow(
@@ -58,11 +100,10 @@ func getSummary(database *sql.DB) gin.HandlerFunc {
 		}
 
 		// Get existing summary
-		var summary db.Summary
-		err = database.QueryRow(
-			"SELECT id, note_id, content, created_at, updated_at FROM summaries WHERE note_id = $1",
-			noteID,
-		).Scan(&summary.ID, &summary.NoteID, &summary.Content, &summary.CreatedAt, &summary.UpdatedAt)
+		summary, err := scanSummary(database.QueryRow(
+			"SELECT "+summaryColumns+" FROM summaries WHERE note_id = $1 AND style = $2",
+			noteID, opts.Style,
+		))
 
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Summary not found"})
@@ -73,11 +114,88 @@ func getSummary(database *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// ListSummaries godoc
+// @Summary List note summaries
+// @Description Get every summary generated for a note, one per style
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Success 200 {array} db.Summary "Note summaries"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/summaries [get]
+func listSummaries(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		// Check if note belongs to user
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		rows, err := database.Query(
+			"SELECT "+summaryColumns+" FROM summaries WHERE note_id = $1 ORDER BY updated_at DESC",
+			noteID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch summaries"})
+			return
+		}
+		defer rows.Close()
+
+		summaries := []db.Summary{}
+		for rows.Next() {
+			summary, err := scanSummary(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan summary"})
+				return
+			}
+			summaries = append(summaries, summary)
+		}
+
+		c.JSON(http.StatusOK, summaries)
+	}
+}
+
+// GenerateSummary godoc
+// @Summary Generate note summary
+// @Description Generate a summary of a note, replacing its existing summary in the same style. The body is optional and sets the style and length.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param async query bool false "Set to false to wait for the result instead of queuing the work" default(true)
+// @Param request body services.SummaryOptions false "Summary options"
+// @Success 202 {object} db.Job "Queued job"
+// @Success 200 {object} db.Summary "Generated summary"
+// @Failure 400 {object} map[string]string "Invalid options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/summary [post]
 func generateSummary(database *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		noteID := c.Param("id")
 
+		var opts services.SummaryOptions
+		if err := bindOptions(c, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts = opts.WithDefaults()
+
 		// Check if note belongs to user and get content
 		var note db.Note
 		err := database.QueryRow(
@@ -89,22 +207,22 @@ func generateSummary(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Run in the background unless asked to wait, replying with the job
+		// to poll
+		if c.Query("async") != "false" {
+			enqueueJob(c, database, note.ID, jobTypeSummary, opts)
+			return
+		}
+
 		// Generate summary using AI
-		summaryContent, err := services.GenerateSummary(note.Content)
+		summaryContent, err := services.GenerateSummary(c.Request.Context(), note.Content, opts)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate summary"})
 			return
 		}
 
 		// Save or update summary
-		var summary db.Summary
-		err = database.QueryRow(
-			`INSERT INTO summaries (note_id, content) VALUES ($1, $2) 
-			 ON CONFLICT (note_id) DO UPDATE SET content = $2, updated_at = CURRENT_TIMESTAMP
-			 RETURNING id, note_id, content, created_at, updated_at`,
-			noteID, summaryContent,
-		).Scan(&summary.ID, &summary.NoteID, &summary.Content, &summary.CreatedAt, &summary.UpdatedAt)
-
+		summary, err := saveSummary(database, nil, noteID, opts, summaryContent)
 		if err != nil {
 			// Log the actual error for debugging
 			fmt.Printf("Failed to save summary for note %s: %v\n", noteID, err)
@@ -140,7 +258,7 @@ func getFlashcards(database *sql.DB) gin.HandlerFunc {
 
 		// Get existing flashcards
 		rows, err := database.Query(
-			"SELECT id, note_id, question, answer, created_at FROM flashcards WHERE note_id = $1 ORDER BY created_at",
+			"SELECT "+flashcardColumns+" FROM flashcards WHERE note_id = $1 ORDER BY created_at",
 			noteID,
 		)
 		if err != nil {
@@ -151,8 +269,7 @@ func getFlashcards(database *sql.DB) gin.HandlerFunc {
 
 		var flashcards []db.Flashcard
 		for rows.Next() {
-			var flashcard db.Flashcard
-			err := rows.Scan(&flashcard.ID, &flashcard.NoteID, &flashcard.Question, &flashcard.Answer, &flashcard.CreatedAt)
+			flashcard, err := scanFlashcard(rows)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan flashcard"})
 				return
@@ -164,11 +281,34 @@ func getFlashcards(database *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// GenerateFlashcards godoc
+// @Summary Generate flashcards
+// @Description Generate flashcards for a note, replacing its existing flashcards. The body is optional and sets the difficulty, cognitive level, number of cards and language.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param async query bool false "Set to false to wait for the result instead of queuing the work" default(true)
+// @Param request body services.GenerationOptions false "Generation options"
+// @Success 202 {object} db.Job "Queued job"
+// @Success 200 {array} db.Flashcard "Generated flashcards"
+// @Failure 400 {object} map[string]string "Invalid options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/flashcards [post]
 func generateFlashcards(database *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		noteID := c.Param("id")
 
+		var opts services.GenerationOptions
+		if err := bindOptions(c, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Check if note belongs to user and get content
 		var note db.Note
 		err := database.QueryRow(
@@ -180,46 +320,62 @@ func generateFlashcards(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Run in the background unless asked to wait, replying with the job
+		// to poll
+		if c.Query("async") != "false" {
+			enqueueJob(c, database, note.ID, jobTypeFlashcards, opts)
+			return
+		}
+
 		// Generate flashcards using AI
-		flashcards, err := services.GenerateFlashcards(note.Content)
+		flashcards, err := services.GenerateFlashcards(c.Request.Context(), note.Content, opts)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate flashcards"})
 			return
 		}
 
-		// Clear existing flashcards for this note
-		_, err = database.Exec("DELETE FROM flashcards WHERE note_id = $1", noteID)
+		// Replace existing flashcards for this note
+		insertedFlashcards, err := replaceFlashcards(database, nil, noteID, flashcards)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear existing flashcards"})
+			// Log the actual error for debugging
+			fmt.Printf("Failed to save flashcards for note %s: %v\n", noteID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save flashcards"})
 			return
 		}
 
-		// Insert new flashcards
-		var insertedFlashcards []db.Flashcard
-		for _, fc := range flashcards {
-			var flashcard db.Flashcard
-			err = database.QueryRow(
-				"INSERT INTO flashcards (note_id, question, answer) VALUES ($1, $2, $3) RETURNING id, note_id, question, answer, created_at",
-				noteID, fc.Question, fc.Answer,
-			).Scan(&flashcard.ID, &flashcard.NoteID, &flashcard.Question, &flashcard.Answer, &flashcard.CreatedAt)
-			if err != nil {
-				// Log the actual error for debugging
-				fmt.Printf("Failed to save flashcard for note %s: %v\n", noteID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save flashcard"})
-				return
-			}
-			insertedFlashcards = append(insertedFlashcards, flashcard)
-		}
-
 		c.JSON(http.StatusOK, insertedFlashcards)
 	}
 }
 
+// activeQuizWindow is how long an uncompleted quiz session keeps the answer
+// key of its questions hidden
+const activeQuizWindow = "1 day"
+
+// GetQuiz godoc
+// @Summary Get quiz
+// @Description Get the quiz for a note. In "take" mode (the default) answers are left out, to be revealed by submitting them to a quiz session. In "review" mode each question includes its answer; it is refused while the user has a quiz session on the note in progress, one started in the last day and not yet completed, unless regenerating the quiz has since replaced its questions.
+// @Tags Study Materials
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param mode query string false "review or take"
+// @Success 200 {array} db.Quiz "Quiz questions"
+// @Failure 400 {object} map[string]string "Invalid mode"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 409 {object} map[string]string "Quiz session in progress"
+// @Router /study/notes/{id}/quiz [get]
 func getQuiz(database *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		noteID := c.Param("id")
 
+		mode := c.DefaultQuery("mode", "take")
+		if mode != "review" && mode != "take" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be review or take"})
+			return
+		}
+
 		// Check if note belongs to user
 		var note db.Note
 		err := database.QueryRow(
@@ -231,9 +387,34 @@ func getQuiz(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// The answer key would give away a quiz being taken. Sessions whose
+		// questions were deleted by regenerating the quiz can't be finished,
+		// so they don't count.
+		if mode == "review" {
+			var active bool
+			err := database.QueryRow(
+				`SELECT EXISTS (
+				   SELECT 1 FROM study_sessions
+				   WHERE user_id = $1 AND NOT completed AND created_at > CURRENT_TIMESTAMP - $3::interval
+				     AND type IN ('quiz', 'adaptive_quiz')
+				     AND (quiz_ids && ARRAY(SELECT id FROM quizzes WHERE note_id = $2) OR (quiz_ids IS NULL AND note_id = $2))
+				     AND NOT EXISTS (SELECT 1 FROM unnest(quiz_ids) AS ids(id) WHERE ids.id NOT IN (SELECT id FROM quizzes))
+				 )`,
+				userID, note.ID, activeQuizWindow,
+			).Scan(&active)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quiz sessions"})
+				return
+			}
+			if active {
+				c.JSON(http.StatusConflict, gin.H{"error": "Finish your quiz session on this note before reviewing the answers"})
+				return
+			}
+		}
+
 		// Get existing quiz questions
 		rows, err := database.Query(
-			"SELECT id, note_id, question, options, answer, created_at FROM quizzes WHERE note_id = $1 ORDER BY created_at",
+			"SELECT "+quizColumns+" FROM quizzes WHERE note_id = $1 ORDER BY created_at, id",
 			noteID,
 		)
 		if err != nil {
@@ -244,28 +425,50 @@ func getQuiz(database *sql.DB) gin.HandlerFunc {
 
 		var quizQuestions []db.Quiz
 		for rows.Next() {
-			var quiz db.Quiz
-			err := rows.Scan(&quiz.ID, &quiz.NoteID, &quiz.Question, pq.Array(&quiz.Options), &quiz.Answer, &quiz.CreatedAt)
+			quiz, err := scanQuiz(rows)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan quiz question"})
 				return
 			}
-			// Debug logging
-			fmt.Printf("Retrieved quiz question: ID=%d, Question=%s, Options=%v, Answer=%d\n", 
-				quiz.ID, quiz.Question, quiz.Options, quiz.Answer)
 			quizQuestions = append(quizQuestions, quiz)
 		}
 
-		fmt.Printf("Returning %d quiz questions\n", len(quizQuestions))
+		if mode == "take" {
+			c.JSON(http.StatusOK, hideAnswers(quizQuestions))
+			return
+		}
 		c.JSON(http.StatusOK, quizQuestions)
 	}
 }
 
+// GenerateQuiz godoc
+// @Summary Generate quiz
+// @Description Generate quiz questions for a note, replacing its existing quiz. The body is optional and picks the question types to mix, along with the difficulty, cognitive level, number of questions and language; without it every question is multiple choice.
+// @Tags Study Materials
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param async query bool false "Set to false to wait for the result instead of queuing the work" default(true)
+// @Param request body services.QuizOptions false "Generation options"
+// @Success 202 {object} db.Job "Queued job"
+// @Success 200 {array} db.Quiz "Generated quiz"
+// @Failure 400 {object} map[string]string "Invalid options"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/quiz [post]
 func generateQuiz(database *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		noteID := c.Param("id")
 
+		var opts services.QuizOptions
+		if err := bindOptions(c, &opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Check if note belongs to user and get content
 		var note db.Note
 		err := database.QueryRow(
@@ -277,42 +480,29 @@ func generateQuiz(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Run in the background unless asked to wait, replying with the job
+		// to poll
+		if c.Query("async") != "false" {
+			enqueueJob(c, database, note.ID, jobTypeQuiz, opts)
+			return
+		}
+
 		// Generate quiz using AI
-		quizQuestions, err := services.GenerateQuiz(note.Content)
+		quizQuestions, err := services.GenerateQuiz(c.Request.Context(), note.Content, opts)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate quiz"})
 			return
 		}
 
-		// Clear existing quiz for this note
-		_, err = database.Exec("DELETE FROM quizzes WHERE note_id = $1", noteID)
+		// Replace existing quiz for this note
+		insertedQuiz, err := replaceQuiz(database, nil, noteID, quizQuestions)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear existing quiz"})
+			// Log the actual error for debugging
+			fmt.Printf("Failed to save quiz for note %s: %v\n", noteID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quiz"})
 			return
 		}
 
-		// Insert new quiz questions
-		var insertedQuiz []db.Quiz
-		for i, q := range quizQuestions {
-			fmt.Printf("Saving quiz question %d: Question=%s, Options=%v, Answer=%d\n", 
-				i+1, q.Question, q.Options, q.Answer)
-			
-			var quiz db.Quiz
-			err = database.QueryRow(
-				"INSERT INTO quizzes (note_id, question, options, answer) VALUES ($1, $2, $3, $4) RETURNING id, note_id, question, options, answer, created_at",
-				noteID, q.Question, pq.Array(q.Options), q.Answer,
-			).Scan(&quiz.ID, &quiz.NoteID, &quiz.Question, pq.Array(&quiz.Options), &quiz.Answer, &quiz.CreatedAt)
-			if err != nil {
-				// Log the actual error for debugging
-				fmt.Printf("Failed to save quiz question for note %s: %v\n", noteID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quiz question"})
-				return
-			}
-			
-			fmt.Printf("Successfully saved quiz question: ID=%d, Options=%v\n", quiz.ID, quiz.Options)
-			insertedQuiz = append(insertedQuiz, quiz)
-		}
-
 		c.JSON(http.StatusOK, insertedQuiz)
 	}
 }
@@ -341,12 +531,14 @@ func createStudySession(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Create study session
-		var session db.StudySession
-		err = database.QueryRow(
-			"INSERT INTO study_sessions (user_id, note_id, type) VALUES ($1, $2, $3) RETURNING id, user_id, note_id, type, score, completed, created_at",
+		// Create study session. A quiz session keeps the questions it
+		// started with, so regenerating the quiz doesn't change it.
+		session, err := scanSession(database.QueryRow(
+			`INSERT INTO study_sessions (user_id, note_id, type, quiz_ids)
+			 VALUES ($1, $2, $3, CASE WHEN $3 = 'quiz' THEN NULLIF(ARRAY(SELECT id FROM quizzes WHERE note_id = $2 ORDER BY created_at, id), '{}') END)
+			 RETURNING `+sessionColumns,
 			userID, req.NoteID, req.Type,
-		).Scan(&session.ID, &session.UserID, &session.NoteID, &session.Type, &session.Score, &session.Completed, &session.CreatedAt)
+		))
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create study session"})
@@ -371,18 +563,249 @@ func updateStudySession(database *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Update study session
-		var session db.StudySession
-		err := database.QueryRow(
-			"UPDATE study_sessions SET score = $1, completed = $2 WHERE id = $3 AND user_id = $4 RETURNING id, user_id, note_id, type, score, completed, created_at",
+		// Update study session. Quiz scores and completion come from graded
+		// answers only, since a completed quiz session reveals its answers.
+		session, err := scanSession(database.QueryRow(
+			`UPDATE study_sessions SET
+			   score = CASE WHEN type IN ('quiz', 'adaptive_quiz') THEN score ELSE $1 END,
+			   completed = CASE WHEN type IN ('quiz', 'adaptive_quiz') THEN completed ELSE $2 END
+			 WHERE id = $3 AND user_id = $4 RETURNING `+sessionColumns,
 			req.Score, req.Completed, sessionID, userID,
-		).Scan(&session.ID, &session.UserID, &session.NoteID, &session.Type, &session.Score, &session.Completed, &session.CreatedAt)
-
+		))
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Study session not found"})
 			return
 		}
+		// Quiz tasks are completed by grading, when the last answer is in
+		if session.Type != "quiz" && session.Type != "adaptive_quiz" {
+			completePlanTasks(database, session)
+		}
 
 		c.JSON(http.StatusOK, session)
 	}
 }
+
+// summaryColumns are the summary columns read by scanSummary
+const summaryColumns = "id, note_id, style, length, content, created_at, updated_at"
+
+// scanSummary reads a summary selected with summaryColumns
+func scanSummary(row rowScanner) (db.Summary, error) {
+	var summary db.Summary
+	err := row.Scan(&summary.ID, &summary.NoteID, &summary.Style, &summary.Length, &summary.Content, &summary.CreatedAt, &summary.UpdatedAt)
+	return summary, err
+}
+
+// sessionColumns are the study session columns read by scanSession
+const sessionColumns = "id, user_id, COALESCE(note_id, 0), type, score, completed, quiz_ids, created_at"
+
+// scanSession reads a study session selected with sessionColumns
+func scanSession(row rowScanner) (db.StudySession, error) {
+	var session db.StudySession
+	err := row.Scan(&session.ID, &session.UserID, &session.NoteID, &session.Type, &session.Score, &session.Completed, pq.Array(&session.QuizIDs), &session.CreatedAt)
+	return session, err
+}
+
+// saveSummary stores the summary for a note, replacing any existing one in
+// the same style. opts must have its defaults filled in.
+func saveSummary(database *sql.DB, job *db.Job, noteID string, opts services.SummaryOptions, content string) (db.Summary, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return db.Summary{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockJob(tx, job); err != nil {
+		return db.Summary{}, err
+	}
+
+	summary, err := scanSummary(tx.QueryRow(
+		`INSERT INTO summaries (note_id, style, length, content) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (note_id, style) DO UPDATE SET length = $3, content = $4, updated_at = CURRENT_TIMESTAMP
+		 RETURNING `+summaryColumns,
+		noteID, opts.Style, opts.Length, content,
+	))
+	if err != nil {
+		return db.Summary{}, err
+	}
+	return summary, tx.Commit()
+}
+
+// replaceFlashcards swaps a note's flashcards for newly generated ones. A
+// card asking the same question as an existing one takes its place, keeping
+// its ID so the user's review history carries over; the rest are deleted.
+func replaceFlashcards(database *sql.DB, job *db.Job, noteID string, flashcards []services.FlashcardData) ([]db.Flashcard, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockJob(tx, job); err != nil {
+		return nil, err
+	}
+
+	existing, err := flashcardIDsByQuestion(tx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	insertedFlashcards := []db.Flashcard{}
+	kept := []int64{}
+	for _, fc := range flashcards {
+		var flashcard db.Flashcard
+		key := questionKey(fc.Question)
+		if id, ok := existing[key]; ok {
+			delete(existing, key)
+			flashcard, err = updateFlashcard(tx, noteID, id, fc)
+		} else {
+			flashcard, err = insertFlashcard(tx, noteID, fc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		kept = append(kept, int64(flashcard.ID))
+		insertedFlashcards = append(insertedFlashcards, flashcard)
+	}
+
+	_, err = tx.Exec("DELETE FROM flashcards WHERE note_id = $1 AND NOT (id = ANY($2::int[]))", noteID, pq.Array(kept))
+	if err != nil {
+		return nil, err
+	}
+
+	return insertedFlashcards, tx.Commit()
+}
+
+// flashcardIDsByQuestion maps the questions of a note's flashcards to their
+// IDs
+func flashcardIDsByQuestion(tx *sql.Tx, noteID string) (map[string]int, error) {
+	rows, err := tx.Query("SELECT id, question FROM flashcards WHERE note_id = $1", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]int{}
+	for rows.Next() {
+		var id int
+		var question string
+		if err := rows.Scan(&id, &question); err != nil {
+			return nil, err
+		}
+		ids[questionKey(question)] = id
+	}
+	return ids, rows.Err()
+}
+
+// questionKey normalises a question for matching, ignoring case and spacing
+func questionKey(question string) string {
+	return strings.ToLower(strings.Join(strings.Fields(question), " "))
+}
+
+// updateFlashcard replaces the answer and source of one of a note's
+// flashcards
+func updateFlashcard(tx *sql.Tx, noteID string, id int, fc services.FlashcardData) (db.Flashcard, error) {
+	start, end := sourceOffsets(fc.Span)
+	return scanFlashcard(tx.QueryRow(
+		`UPDATE flashcards SET question = $2, answer = $3, explanation = NULLIF($4, ''), source_start = $5, source_end = $6,
+		   source_chunk_id = `+sourceChunkQuery+`
+		 WHERE id = $7 AND note_id = $1
+		 RETURNING `+flashcardColumns,
+		noteID, fc.Question, fc.Answer, fc.Explanation, start, end, id,
+	))
+}
+
+// insertFlashcard adds a flashcard to a note, linking it to the chunk that
+// holds its source
+func insertFlashcard(tx *sql.Tx, noteID string, fc services.FlashcardData) (db.Flashcard, error) {
+	start, end := sourceOffsets(fc.Span)
+	return scanFlashcard(tx.QueryRow(
+		`INSERT INTO flashcards (note_id, question, answer, explanation, source_start, source_end, source_chunk_id)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, `+sourceChunkQuery+`)
+		 RETURNING `+flashcardColumns,
+		noteID, fc.Question, fc.Answer, fc.Explanation, start, end,
+	))
+}
+
+// replaceQuiz swaps a note's quiz for newly generated questions
+func replaceQuiz(database *sql.DB, job *db.Job, noteID string, quizQuestions []services.QuizData) ([]db.Quiz, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockJob(tx, job); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM quizzes WHERE note_id = $1", noteID); err != nil {
+		return nil, err
+	}
+
+	insertedQuiz := []db.Quiz{}
+	for _, q := range quizQuestions {
+		start, end := sourceOffsets(q.Span)
+		var answers []int64
+		for _, answer := range q.Answers {
+			answers = append(answers, int64(answer))
+		}
+		quiz, err := scanQuiz(tx.QueryRow(
+			`INSERT INTO quizzes (note_id, question, options, answer, source_start, source_end, shuffle_seed, explanation, type, answers, answer_text, rubric, source_chunk_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, NULLIF($11, ''), $12, `+sourceChunkQuery+`)
+			 RETURNING `+quizColumns,
+			noteID, q.Question, pq.Array(q.Options), q.Answer, start, end, q.Seed, q.Explanation, q.Type, pq.Array(answers), q.AnswerText, pq.Array(q.Rubric),
+		))
+		if err != nil {
+			return nil, err
+		}
+		insertedQuiz = append(insertedQuiz, quiz)
+	}
+
+	return insertedQuiz, tx.Commit()
+}
+
+// sourceChunkQuery finds the chunk of note $1 holding the source passage at
+// byte offsets $5 to $6. Chunks overlap, so a passage crossing a boundary
+// gets the chunk it starts in.
+const sourceChunkQuery = `(SELECT id FROM note_chunks WHERE note_id = $1 AND start_offset <= $5 AND end_offset > $5
+	ORDER BY end_offset >= $6 DESC, chunk_index LIMIT 1)`
+
+// sourceOffsets unpacks a source span into nullable columns
+func sourceOffsets(span *services.SourceSpan) (start, end *int) {
+	if span == nil {
+		return nil, nil
+	}
+	return &span.Start, &span.End
+}
+
+const flashcardColumns = "id, note_id, question, answer, COALESCE(explanation, ''), source_chunk_id, source_start, source_end, created_at"
+
+// scanFlashcard reads a flashcard selected with flashcardColumns
+func scanFlashcard(row rowScanner) (db.Flashcard, error) {
+	var flashcard db.Flashcard
+	err := row.Scan(&flashcard.ID, &flashcard.NoteID, &flashcard.Question, &flashcard.Answer, &flashcard.Explanation, &flashcard.SourceChunkID, &flashcard.SourceStart, &flashcard.SourceEnd, &flashcard.CreatedAt)
+	return flashcard, err
+}
+
+const quizColumns = "id, note_id, type, question, options, answer, answers, COALESCE(answer_text, ''), rubric, shuffle_seed, COALESCE(explanation, ''), source_chunk_id, source_start, source_end, created_at"
+
+// bindOptions decodes generation options from the body and checks them. The
+// body is optional, leaving opts as it is when empty.
+func bindOptions(c *gin.Context, opts interface{ Validate() error }) error {
+	if err := c.ShouldBindJSON(opts); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return opts.Validate()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanQuiz reads a quiz question selected with quizColumns
+func scanQuiz(row rowScanner) (db.Quiz, error) {
+	var quiz db.Quiz
+	err := row.Scan(&quiz.ID, &quiz.NoteID, &quiz.Type, &quiz.Question, pq.Array(&quiz.Options), &quiz.Answer, pq.Array(&quiz.Answers), &quiz.AnswerText, pq.Array(&quiz.Rubric), &quiz.ShuffleSeed, &quiz.Explanation, &quiz.SourceChunkID, &quiz.SourceStart, &quiz.SourceEnd, &quiz.CreatedAt)
+	return quiz, err
+}
@@ -0,0 +1,434 @@
+package study
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"studypartner/db"
+	"studypartner/routes/notes"
+	"studypartner/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tutorPassages is how many note passages ground each tutor reply
+const tutorPassages = 4
+
+type CreateConversationRequest struct {
+	Mode  string `json:"mode"` // "explain" (default), "socratic" or "quiz"
+	Title string `json:"title"`
+}
+
+type UpdateConversationRequest struct {
+	Mode  string `json:"mode"`
+	Title string `json:"title"`
+}
+
+type AppendMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+	Mode    string `json:"mode"` // Optionally switch mode for this and later turns
+}
+
+// tutorTurn holds everything needed to generate the tutor's next reply
+type tutorTurn struct {
+	Conversation db.Conversation
+	History      []services.TutorMessage
+	Message      string
+	Passages     []services.Passage
+}
+
+// CreateConversation godoc
+// @Summary Start a tutor conversation
+// @Description Start a multi-turn tutor conversation grounded in a note
+// @Tags Tutor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param request body CreateConversationRequest false "Conversation options"
+// @Success 201 {object} db.Conversation "Conversation created"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/notes/{id}/conversations [post]
+func createConversation(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		noteID := c.Param("id")
+
+		var req CreateConversationRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if req.Mode == "" {
+			req.Mode = services.TutorModeExplain
+		}
+		if !services.ValidTutorMode(req.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be one of explain, socratic or quiz"})
+			return
+		}
+
+		// Check if note belongs to user
+		var note db.Note
+		err := database.QueryRow(
+			"SELECT id, title FROM notes WHERE id = $1 AND user_id = $2",
+			noteID, userID,
+		).Scan(&note.ID, &note.Title)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+			return
+		}
+
+		if req.Title == "" {
+			req.Title = note.Title
+		}
+
+		var conversation db.Conversation
+		err = database.QueryRow(
+			`INSERT INTO conversations (user_id, note_id, mode, title) VALUES ($1, $2, $3, $4)
+			 RETURNING id, user_id, note_id, mode, title, created_at, updated_at`,
+			userID, note.ID, req.Mode, req.Title,
+		).Scan(&conversation.ID, &conversation.UserID, &conversation.NoteID, &conversation.Mode, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversation"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, conversation)
+	}
+}
+
+// ListConversations godoc
+// @Summary List tutor conversations
+// @Description List the user's tutor conversations, optionally for one note
+// @Tags Tutor
+// @Produce json
+// @Security BearerAuth
+// @Param note_id query int false "Only conversations about this note"
+// @Success 200 {array} db.Conversation "Conversations, most recent first"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/conversations [get]
+func listConversations(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		rows, err := database.Query(
+			`SELECT id, user_id, note_id, mode, title, created_at, updated_at FROM conversations
+			 WHERE user_id = $1 AND ($2 = '' OR note_id::text = $2)
+			 ORDER BY updated_at DESC`,
+			userID, c.Query("note_id"),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
+			return
+		}
+		defer rows.Close()
+
+		conversations := []db.Conversation{}
+		for rows.Next() {
+			var conversation db.Conversation
+			err := rows.Scan(&conversation.ID, &conversation.UserID, &conversation.NoteID, &conversation.Mode, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan conversation"})
+				return
+			}
+			conversations = append(conversations, conversation)
+		}
+
+		c.JSON(http.StatusOK, conversations)
+	}
+}
+
+// GetConversation godoc
+// @Summary Get a tutor conversation
+// @Description Get a tutor conversation with its full message history
+// @Tags Tutor
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Conversation ID"
+// @Success 200 {object} db.Conversation "Conversation with messages"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Conversation not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/conversations/{id} [get]
+func getConversation(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		conversation, err := loadConversation(database, c.Param("id"), userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		conversation.Messages, err = loadMessages(c.Request.Context(), database, conversation.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+			return
+		}
+
+		c.JSON(http.StatusOK, conversation)
+	}
+}
+
+// UpdateConversation godoc
+// @Summary Update a tutor conversation
+// @Description Switch the tutor mode or rename a conversation
+// @Tags Tutor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Conversation ID"
+// @Param request body UpdateConversationRequest true "Fields to update"
+// @Success 200 {object} db.Conversation "Updated conversation"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Conversation not found"
+// @Router /study/conversations/{id} [put]
+func updateConversation(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req UpdateConversationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Mode != "" && !services.ValidTutorMode(req.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be one of explain, socratic or quiz"})
+			return
+		}
+
+		var conversation db.Conversation
+		err := database.QueryRow(
+			`UPDATE conversations SET mode = COALESCE(NULLIF($1, ''), mode), title = COALESCE(NULLIF($2, ''), title), updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $3 AND user_id = $4
+			 RETURNING id, user_id, note_id, mode, title, created_at, updated_at`,
+			req.Mode, req.Title, c.Param("id"), userID,
+		).Scan(&conversation.ID, &conversation.UserID, &conversation.NoteID, &conversation.Mode, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, conversation)
+	}
+}
+
+// AppendMessage godoc
+// @Summary Send a message to the tutor
+// @Description Append a student message to a conversation and get the tutor's reply, grounded in the note
+// @Tags Tutor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Conversation ID"
+// @Param request body AppendMessageRequest true "Student message"
+// @Success 201 {array} db.ConversationMessage "The student message and the tutor reply"
+// @Failure 400 {object} map[string]string "Invalid request data"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Conversation not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/conversations/{id}/messages [post]
+func appendMessage(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		var req AppendMessageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		turn, status, err := prepareTutorTurn(c.Request.Context(), database, c.Param("id"), userID.(int), req)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		reply, err := services.TutorReply(c.Request.Context(), turn.Conversation.Mode, turn.History, turn.Message, turn.Passages)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reply"})
+			return
+		}
+
+		messages, err := saveTutorTurn(c.Request.Context(), database, turn, reply)
+		if err != nil {
+			fmt.Printf("Failed to save messages for conversation %d: %v\n", turn.Conversation.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save messages"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, messages)
+	}
+}
+
+// DeleteConversation godoc
+// @Summary Delete a tutor conversation
+// @Description Delete a tutor conversation and its messages
+// @Tags Tutor
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Conversation ID"
+// @Success 200 {object} map[string]string "Conversation deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Conversation not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /study/conversations/{id} [delete]
+func deleteConversation(database *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+
+		result, err := database.Exec("DELETE FROM conversations WHERE id = $1 AND user_id = $2", c.Param("id"), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete conversation"})
+			return
+		}
+
+		rowsAffected, _ := result.RowsAffected()
+		if rowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted successfully"})
+	}
+}
+
+// prepareTutorTurn loads the conversation, its history and the note
+// passages relevant to the new message. On failure it also returns the
+// HTTP status to respond with.
+func prepareTutorTurn(ctx context.Context, database *sql.DB, conversationID string, userID int, req AppendMessageRequest) (tutorTurn, int, error) {
+	var turn tutorTurn
+
+	if req.Mode != "" && !services.ValidTutorMode(req.Mode) {
+		return turn, http.StatusBadRequest, errors.New("Mode must be one of explain, socratic or quiz")
+	}
+
+	conversation, err := loadConversation(database, conversationID, userID)
+	if err != nil {
+		return turn, http.StatusNotFound, errors.New("Conversation not found")
+	}
+	if req.Mode != "" {
+		conversation.Mode = req.Mode
+	}
+
+	messages, err := loadMessages(ctx, database, conversation.ID)
+	if err != nil {
+		return turn, http.StatusInternalServerError, errors.New("Failed to fetch messages")
+	}
+
+	history := make([]services.TutorMessage, 0, len(messages))
+	for _, message := range messages {
+		history = append(history, services.TutorMessage{Role: message.Role, Content: message.Content})
+	}
+
+	// Short replies like "photosynthesis?" only make sense together with the
+	// tutor's previous message, so search with both
+	query := req.Content
+	if len(history) > 0 && history[len(history)-1].Role == "assistant" {
+		query = history[len(history)-1].Content + "\n" + req.Content
+	}
+
+	passages, err := notes.RetrievePassages(ctx, database, userID, conversation.NoteID, query, tutorPassages)
+	if err != nil {
+		fmt.Printf("Failed to retrieve passages for conversation %d: %v\n", conversation.ID, err)
+		return turn, http.StatusInternalServerError, errors.New("Failed to search note")
+	}
+
+	return tutorTurn{
+		Conversation: conversation,
+		History:      history,
+		Message:      req.Content,
+		Passages:     passages,
+	}, http.StatusOK, nil
+}
+
+// saveTutorTurn stores the student message and the tutor reply and bumps
+// the conversation, persisting any mode switch
+func saveTutorTurn(ctx context.Context, database *sql.DB, turn tutorTurn, reply services.Answer) ([]db.ConversationMessage, error) {
+	citations, err := json.Marshal(reply.Citations)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	messages := make([]db.ConversationMessage, 2)
+	err = tx.QueryRow(
+		`INSERT INTO conversation_messages (conversation_id, role, content) VALUES ($1, 'user', $2)
+		 RETURNING id, conversation_id, role, content, created_at`,
+		turn.Conversation.ID, turn.Message,
+	).Scan(&messages[0].ID, &messages[0].ConversationID, &messages[0].Role, &messages[0].Content, &messages[0].CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO conversation_messages (conversation_id, role, content, citations) VALUES ($1, 'assistant', $2, $3)
+		 RETURNING id, conversation_id, role, content, created_at`,
+		turn.Conversation.ID, reply.Answer, citations,
+	).Scan(&messages[1].ID, &messages[1].ConversationID, &messages[1].Role, &messages[1].Content, &messages[1].CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	messages[1].Citations = citations
+
+	_, err = tx.Exec(
+		"UPDATE conversations SET mode = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		turn.Conversation.Mode, turn.Conversation.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, tx.Commit()
+}
+
+// loadConversation fetches a conversation owned by the user
+func loadConversation(database *sql.DB, conversationID string, userID interface{}) (db.Conversation, error) {
+	var conversation db.Conversation
+	err := database.QueryRow(
+		"SELECT id, user_id, note_id, mode, title, created_at, updated_at FROM conversations WHERE id = $1 AND user_id = $2",
+		conversationID, userID,
+	).Scan(&conversation.ID, &conversation.UserID, &conversation.NoteID, &conversation.Mode, &conversation.Title, &conversation.CreatedAt, &conversation.UpdatedAt)
+	return conversation, err
+}
+
+// loadMessages fetches a conversation's messages in order
+func loadMessages(ctx context.Context, database *sql.DB, conversationID int) ([]db.ConversationMessage, error) {
+	rows, err := database.QueryContext(ctx,
+		"SELECT id, conversation_id, role, content, citations, created_at FROM conversation_messages WHERE conversation_id = $1 ORDER BY created_at, id",
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []db.ConversationMessage{}
+	for rows.Next() {
+		var message db.ConversationMessage
+		var citations []byte
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.Role, &message.Content, &citations, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		message.Citations = citations
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
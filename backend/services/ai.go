@@ -1,248 +1,384 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
 	"strings"
 )
 
-
-type HuggingFaceRequest struct {
-	Inputs string `json:"inputs"`
-}
-
-type HuggingFaceResponse struct {
-	GeneratedText string `json:"generated_text"`
+// GenerateSummary creates a summary of the given text using AI, in the
+// style and length picked by opts
+func GenerateSummary(ctx context.Context, content string, opts SummaryOptions) (string, error) {
+	return StreamSummary(ctx, content, opts, nil)
 }
 
-// GenerateSummary creates a summary of the given text using AI
-func GenerateSummary(content string) (string, error) {
+// StreamSummary creates a summary like GenerateSummary, passing the text to
+// onToken as it is generated. onToken may be nil.
+func StreamSummary(ctx context.Context, content string, opts SummaryOptions, onToken TokenFunc) (string, error) {
 	// Validate input content
 	if strings.TrimSpace(content) == "" {
 		return "", fmt.Errorf("content cannot be empty")
 	}
+	opts = opts.WithDefaults()
 
-	// Create a proper summarization prompt
-	prompt := fmt.Sprintf(`Please provide a comprehensive summary of the following text. The summary should be clear, well-structured, and capture the main points and key concepts. Do NOT just repeat the title, author, or abstract. Create a meaningful summary that explains the content:
-
-%s
-
-Summary:`, content)
+	stream := newTokenStream(onToken)
 
-	// Try multiple AI models for better results
-	models := []string{
-		"facebook/bart-large-cnn",
-		"google/pegasus-xsum",
-		"microsoft/DialoGPT-medium",
-	}
-
-	for _, model := range models {
-		summary, err := callHuggingFace(model, prompt)
+	// Long documents don't fit in one prompt, summarize them section by section
+	sections := currentSummarizationOptions()
+	if EstimateTokens(content) > sections.ContextTokens {
+		summary, err := summarizeLong(ctx, content, sections, opts, stream)
 		if err != nil {
-			fmt.Printf("HuggingFace API failed for model %s: %v\n", model, err)
-			continue
+			return "", err
 		}
-
-		// Validate the AI response
-		summary = strings.TrimSpace(summary)
-		if summary != "" && len(summary) >= 50 {
-			fmt.Printf("Successfully generated summary using model %s\n", model)
-			return summary, nil
+		if !stream.started {
+			if err := stream.emit(summary); err != nil {
+				return "", err
+			}
 		}
+		return summary, nil
 	}
 
-	// If all AI models fail, use enhanced fallback
-	fmt.Printf("All AI models failed, using enhanced fallback summary\n")
-	return createSimpleSummary(content), nil
-}
-
-// GenerateFlashcards creates flashcards from the given text
-func GenerateFlashcards(content string) ([]FlashcardData, error) {
-	// Try AI services if available, otherwise create simple flashcards
-	prompt := fmt.Sprintf(`Create 6-8 comprehensive educational flashcards from the following text. Each flashcard should have a clear, specific question and a detailed, accurate answer. Make questions diverse and cover different aspects of the content. Format the response as valid JSON with this exact structure:
-[
-  {"question": "What is the main topic discussed?", "answer": "The main topic is..."},
-  {"question": "What are the key concepts?", "answer": "The key concepts include..."},
-  {"question": "How does this work?", "answer": "This works by..."},
-  {"question": "What are the implications?", "answer": "The implications are..."}
-]
+	// Create a proper summarization prompt
+	prompt := fmt.Sprintf(`Summarize the following text as %s. Keep it to at most %d words and capture the main points and key concepts. Do NOT just repeat the title, author, or abstract. Create a meaningful summary that explains the content:
 
-Text to create flashcards from:
 %s
 
-Return only the JSON array, no additional text:`, content)
+Summary:`, opts.format(), opts.words(), quoteNotes(content))
 
-	// Try multiple AI models for better results
-	models := []string{
-		"microsoft/DialoGPT-medium",
-		"facebook/bart-large-cnn",
-		"google/pegasus-xsum",
-	}
-
-	for _, model := range models {
-		response, err := callHuggingFace(model, prompt)
-		if err != nil {
-			fmt.Printf("HuggingFace API failed for flashcards with model %s: %v\n", model, err)
-			continue
+	provider := CurrentProvider()
+	summary, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskSummary}, stream.callback())
+	if err != nil {
+		fmt.Printf("AI provider %s failed for summary: %v\n", provider.Name(), err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
 		}
+	}
 
-		// Clean the response - remove any non-JSON text
-		response = strings.TrimSpace(response)
-		if strings.Contains(response, "```json") {
-			start := strings.Index(response, "```json") + 7
-			end := strings.Index(response[start:], "```")
-			if end != -1 {
-				response = response[start : start+end]
-			}
-		} else if strings.Contains(response, "```") {
-			start := strings.Index(response, "```") + 3
-			end := strings.Index(response[start:], "```")
-			if end != -1 {
-				response = response[start : start+end]
+	// Validate the AI response
+	summary = strings.TrimSpace(summary)
+	if err == nil && len(summary) >= 50 {
+		if !stream.started {
+			if err := stream.emit(summary); err != nil {
+				return "", err
 			}
 		}
+		return summary, nil
+	}
 
-		// Parse JSON response
-		var flashcards []FlashcardData
-		if err := json.Unmarshal([]byte(response), &flashcards); err != nil {
-			fmt.Printf("JSON parsing failed for flashcards with model %s: %v\n", model, err)
-			continue
+	// Output the client has already seen can't be replaced by the fallback
+	if stream.started {
+		if err != nil {
+			return "", err
 		}
+		return summary, nil
+	}
 
-		// Validate flashcards
-		if len(flashcards) > 0 {
-			fmt.Printf("Successfully generated flashcards using model %s\n", model)
-			return flashcards, nil
-		}
+	// If the provider fails, use enhanced fallback
+	fmt.Printf("AI summary unavailable, using enhanced fallback summary\n")
+	summary = styleSimpleSummary(createSimpleSummary(content), opts.Style)
+	if err := stream.emit(summary); err != nil {
+		return "", err
 	}
+	return summary, nil
+}
 
-	// If all AI models fail, use enhanced fallback
-	fmt.Printf("All AI models failed for flashcards, using enhanced fallback\n")
-	return createSimpleFlashcards(content), nil
+// flashcardsSchema describes the structured output expected for flashcards
+var flashcardsSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"flashcards": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"question":    map[string]interface{}{"type": "string"},
+					"answer":      map[string]interface{}{"type": "string"},
+					"explanation": map[string]interface{}{"type": "string"},
+					"source":      map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"question", "answer", "explanation", "source"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"flashcards"},
+	"additionalProperties": false,
 }
 
-// GenerateQuiz creates quiz questions from the given text
-func GenerateQuiz(content string) ([]QuizData, error) {
-	// Try AI services if available, otherwise create simple quiz
-	prompt := fmt.Sprintf(`Create 6-8 comprehensive multiple choice quiz questions from the following text. Each question should have 4 unique, plausible options with one correct answer. Make questions diverse and cover different aspects of the content. Ensure all options are different and meaningful. Format the response as valid JSON with this exact structure:
-[
-  {
-    "question": "What is the main topic discussed?",
-    "options": ["The correct answer", "A plausible but wrong answer", "Another wrong option", "A third wrong option"],
-    "answer": 0
-  }
-]
+// quizSchema describes the structured output expected for quiz questions
+var quizSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"quiz": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type":     map[string]interface{}{"type": "string", "enum": QuestionTypes},
+					"question": map[string]interface{}{"type": "string"},
+					"options": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "string"},
+					},
+					"answer": map[string]interface{}{"type": "integer"},
+					"answers": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "integer"},
+					},
+					"answer_text": map[string]interface{}{"type": "string"},
+					"rubric": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "string"},
+					},
+					"explanation": map[string]interface{}{"type": "string"},
+					"source":      map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"type", "question", "options", "answer", "answers", "answer_text", "rubric", "explanation", "source"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"quiz"},
+	"additionalProperties": false,
+}
 
-Text to create quiz from:
-%s
+// GenerateFlashcards creates flashcards from the given text
+func GenerateFlashcards(ctx context.Context, content string, opts GenerationOptions) ([]FlashcardData, error) {
+	return StreamFlashcards(ctx, content, opts, nil)
+}
 
-Return only the JSON array, no additional text:`, content)
+// StreamFlashcards creates flashcards like GenerateFlashcards, passing each
+// card to onCard as soon as it is complete. onCard may be nil.
+func StreamFlashcards(ctx context.Context, content string, opts GenerationOptions, onCard func(FlashcardData) error) ([]FlashcardData, error) {
+	provider := CurrentProvider()
+	structured := supportsStructuredOutput(provider)
 
-	// Try multiple AI models for better results
-	models := []string{
-		"microsoft/DialoGPT-medium",
-		"facebook/bart-large-cnn",
-		"google/pegasus-xsum",
+	// Try AI services if available, otherwise create simple flashcards
+	format := `[
+  {"question": "What is the main topic discussed?", "answer": "The main topic is...", "explanation": "The text opens by...", "source": "A sentence copied exactly from the text"},
+  {"question": "What are the key concepts?", "answer": "The key concepts include...", "explanation": "These are the ideas the text...", "source": "A sentence copied exactly from the text"},
+  {"question": "How does this work?", "answer": "This works by...", "explanation": "The text describes...", "source": "A sentence copied exactly from the text"},
+  {"question": "What are the implications?", "answer": "The implications are...", "explanation": "The text concludes...", "source": "A sentence copied exactly from the text"}
+]`
+	if structured {
+		format = fmt.Sprintf(`{"flashcards": %s}`, format)
 	}
+	prompt := fmt.Sprintf(`Create %s comprehensive educational flashcards from the following text. Each flashcard should have a clear, specific question, a detailed, accurate answer, a short explanation of why the answer is right, and the source sentence the card is based on, copied word for word from the text. Make questions diverse and cover different aspects of the content.%s
 
-	for _, model := range models {
-		response, err := callHuggingFace(model, prompt)
-		if err != nil {
-			fmt.Printf("HuggingFace API failed for quiz with model %s: %v\n", model, err)
-			continue
-		}
+Format the response as valid JSON with this exact structure:
+%s
 
-		// Clean the response - remove any non-JSON text
-		response = strings.TrimSpace(response)
-		if strings.Contains(response, "```json") {
-			start := strings.Index(response, "```json") + 7
-			end := strings.Index(response[start:], "```")
-			if end != -1 {
-				response = response[start : start+end]
-			}
-		} else if strings.Contains(response, "```") {
-			start := strings.Index(response, "```") + 3
-			end := strings.Index(response[start:], "```")
-			if end != -1 {
-				response = response[start : start+end]
+Text to create flashcards from:
+%s
+
+Return only the JSON, no additional text:`, opts.count("6-8"), opts.instructions(), format, quoteNotes(content))
+
+	// Pick finished cards out of the JSON as it streams in
+	var streamed []FlashcardData
+	var onToken TokenFunc
+	if onCard != nil {
+		scanner := &objectScanner{}
+		onToken = func(token string) error {
+			for _, object := range scanner.write(token) {
+				var card FlashcardData
+				if json.Unmarshal(object, &card) != nil || card.Question == "" {
+					continue
+				}
+				if opts.Count > 0 && len(streamed) >= opts.Count {
+					continue
+				}
+				card = withFlashcardSource(content, card)
+				streamed = append(streamed, card)
+				if err := onCard(card); err != nil {
+					return err
+				}
 			}
+			return nil
 		}
+	}
 
+	response, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskFlashcards, JSONSchema: flashcardsSchema}, onToken)
+	if err != nil {
+		fmt.Printf("AI provider %s failed for flashcards: %v\n", provider.Name(), err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+	} else {
 		// Parse JSON response
-		var quiz []QuizData
-		if err := json.Unmarshal([]byte(response), &quiz); err != nil {
-			fmt.Printf("JSON parsing failed for quiz with model %s: %v\n", model, err)
-			continue
+		var flashcards []FlashcardData
+		if err := decodeGenerated(response, structured, "flashcards", &flashcards); err != nil {
+			fmt.Printf("JSON parsing failed for flashcards from %s: %v\n", provider.Name(), err)
+		} else if len(flashcards) > 0 {
+			if opts.Count > 0 && len(flashcards) > opts.Count {
+				flashcards = flashcards[:opts.Count]
+			}
+			for i := range flashcards {
+				flashcards[i] = withFlashcardSource(content, flashcards[i])
+			}
+			return flashcards, emitCards(flashcards[min(len(streamed), len(flashcards)):], onCard)
 		}
+	}
 
-		// Validate quiz
-		if len(quiz) > 0 {
-			fmt.Printf("Successfully generated quiz using model %s\n", model)
-			return quiz, nil
+	// Cards the client has already seen can't be replaced by the fallback
+	if len(streamed) > 0 {
+		return streamed, nil
+	}
+
+	// If the provider fails, use enhanced fallback
+	fmt.Printf("AI flashcards unavailable, using enhanced fallback\n")
+	flashcards := createSimpleFlashcards(content)
+	if opts.Count > 0 && len(flashcards) > opts.Count {
+		flashcards = flashcards[:opts.Count]
+	}
+	for i := range flashcards {
+		flashcards[i] = withFlashcardSource(content, flashcards[i])
+	}
+	return flashcards, emitCards(flashcards, onCard)
+}
+
+// emitCards passes cards that weren't streamed to onCard
+func emitCards(cards []FlashcardData, onCard func(FlashcardData) error) error {
+	if onCard == nil {
+		return nil
+	}
+	for _, card := range cards {
+		if err := onCard(card); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// If all AI models fail, use enhanced fallback
-	fmt.Printf("All AI models failed for quiz, using enhanced fallback\n")
-	return createSimpleQuiz(content), nil
+// questionFormats tell the model how to write each question type, with an
+// example
+var questionFormats = map[string]struct {
+	rule    string
+	example string
+}{
+	QuestionMultipleChoice: {
+		rule:    `4 unique, plausible options with the index of the one correct option in "answer"`,
+		example: `{"type": "multiple_choice", "question": "What is the main topic discussed?", "options": ["The correct answer", "A plausible but wrong answer", "Another wrong option", "A third wrong option"], "answer": 0, "answers": [], "answer_text": "", "rubric": [], "explanation": "Why the correct answer is right and the others are not", "source": "A sentence copied exactly from the text"}`,
+	},
+	QuestionTrueFalse: {
+		rule:    `a statement as the question, options ["True", "False"] and "answer" 0 if it is true or 1 if it is false`,
+		example: `{"type": "true_false", "question": "A statement about the text that is either true or false.", "options": ["True", "False"], "answer": 1, "answers": [], "answer_text": "", "rubric": [], "explanation": "Why the statement is false", "source": "A sentence copied exactly from the text"}`,
+	},
+	QuestionMultiSelect: {
+		rule:    `4-6 unique options with the indexes of every correct option in "answers"`,
+		example: `{"type": "multi_select", "question": "Which of these are described in the text?", "options": ["A correct option", "A wrong option", "Another correct option", "Another wrong option"], "answer": 0, "answers": [0, 2], "answer_text": "", "rubric": [], "explanation": "Why those options are right", "source": "A sentence copied exactly from the text"}`,
+	},
+	QuestionCloze: {
+		rule:    `a sentence from the text with one key term replaced by "_____" and the missing term in "answer_text"`,
+		example: `{"type": "cloze", "question": "The process that turns light into chemical energy is called _____.", "options": [], "answer": 0, "answers": [], "answer_text": "photosynthesis", "rubric": [], "explanation": "Why this term fits", "source": "A sentence copied exactly from the text"}`,
+	},
+	QuestionShortAnswer: {
+		rule:    `a question answered in a sentence or two, with a model answer in "answer_text" and the 1-3 key points it must make in "rubric"`,
+		example: `{"type": "short_answer", "question": "Why does the author argue that...?", "options": [], "answer": 0, "answers": [], "answer_text": "A complete model answer", "rubric": ["A key point the answer must make"], "explanation": "Why the model answer is right", "source": "A sentence copied exactly from the text"}`,
+	},
+	QuestionEssay: {
+		rule:    `an open question needing a paragraph or more, with a model answer in "answer_text" and the 3-5 key points a full answer covers in "rubric"`,
+		example: `{"type": "essay", "question": "Discuss how...", "options": [], "answer": 0, "answers": [], "answer_text": "A complete model answer", "rubric": ["A key point", "Another key point", "A third key point"], "explanation": "What a strong answer shows", "source": "A sentence copied exactly from the text"}`,
+	},
 }
 
+// GenerateQuiz creates quiz questions from the given text
+func GenerateQuiz(ctx context.Context, content string, opts QuizOptions) ([]QuizData, error) {
+	provider := CurrentProvider()
+	structured := supportsStructuredOutput(provider)
 
-// callHuggingFace makes a request to HuggingFace API
-func callHuggingFace(model, prompt string) (string, error) {
-	reqBody := HuggingFaceRequest{
-		Inputs: prompt,
+	types := opts.Types
+	if len(types) == 0 {
+		types = []string{QuestionMultipleChoice}
 	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+	var rules, examples []string
+	for _, t := range types {
+		rules = append(rules, fmt.Sprintf("- %s: %s", t, questionFormats[t].rule))
+		examples = append(examples, "  "+questionFormats[t].example)
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://api-inference.huggingface.co/models/%s", model), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	// Try AI services if available, otherwise create simple quiz
+	format := fmt.Sprintf("[\n%s\n]", strings.Join(examples, ",\n"))
+	if structured {
+		format = fmt.Sprintf(`{"quiz": %s}`, format)
 	}
+	prompt := fmt.Sprintf(`Create %s comprehensive quiz questions from the following text, mixing these question types:
+%s
 
-	req.Header.Set("Content-Type", "application/json")
-	
-	// Add HuggingFace API key if available
-	if apiKey := os.Getenv("HUGGINGFACE_API_KEY"); apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+apiKey)
-	}
+Make questions diverse and cover different aspects of the content. Ensure all options are different and meaningful. Set "type" on every question and leave the fields its type doesn't use empty. Give each question a short explanation of why the correct answer is right and the source sentence it is based on, copied word for word from the text.%s
+
+Format the response as valid JSON with this exact structure:
+%s
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+Text to create quiz from:
+%s
+
+Return only the JSON, no additional text:`, opts.count("6-8"), strings.Join(rules, "\n"), opts.instructions(), format, quoteNotes(content))
+
+	response, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskQuiz, JSONSchema: quizSchema}, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		fmt.Printf("AI provider %s failed for quiz: %v\n", provider.Name(), err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+	} else {
+		// Parse JSON response
+		var quiz []QuizData
+		if err := decodeGenerated(response, structured, "quiz", &quiz); err != nil {
+			fmt.Printf("JSON parsing failed for quiz from %s: %v\n", provider.Name(), err)
+		} else if quiz = prepareQuiz(content, quiz); len(quiz) > 0 {
+			if opts.Count > 0 && len(quiz) > opts.Count {
+				quiz = quiz[:opts.Count]
+			}
+			return quiz, nil
+		}
 	}
-	defer resp.Body.Close()
 
-	// Check for HTTP errors
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("HuggingFace API error: status %d, body: %s", resp.StatusCode, string(body))
+	// If the provider fails, use enhanced fallback
+	fmt.Printf("AI quiz unavailable, using enhanced fallback\n")
+	quiz := prepareQuiz(content, convertSimpleQuiz(createSimpleQuiz(content), types))
+	if opts.Count > 0 && len(quiz) > opts.Count {
+		quiz = quiz[:opts.Count]
 	}
+	return quiz, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+// decodeGenerated parses a generated JSON list into out. Structured output
+// arrives as an object wrapping the list under key; free text has the list
+// scraped out of any surrounding prose or code fences.
+func decodeGenerated(response string, structured bool, key string, out interface{}) error {
+	if !structured {
+		return json.Unmarshal([]byte(extractJSON(response)), out)
 	}
 
-	var hfResp []HuggingFaceResponse
-	if err := json.Unmarshal(body, &hfResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(response), &wrapper); err != nil {
+		return err
 	}
-
-	if len(hfResp) > 0 {
-		return hfResp[0].GeneratedText, nil
+	list, ok := wrapper[key]
+	if !ok {
+		return fmt.Errorf("missing %q in structured response", key)
 	}
+	return json.Unmarshal(list, out)
+}
 
-	return "", fmt.Errorf("no response from HuggingFace")
+// extractJSON strips surrounding text and ``` fences from a model response
+func extractJSON(response string) string {
+	response = strings.TrimSpace(response)
+	if strings.Contains(response, "```json") {
+		start := strings.Index(response, "```json") + 7
+		end := strings.Index(response[start:], "```")
+		if end != -1 {
+			response = response[start : start+end]
+		}
+	} else if strings.Contains(response, "```") {
+		start := strings.Index(response, "```") + 3
+		end := strings.Index(response[start:], "```")
+		if end != -1 {
+			response = response[start : start+end]
+		}
+	}
+	return strings.TrimSpace(response)
 }
 
 // createSimpleSummary creates a comprehensive summary when AI fails
@@ -327,6 +463,32 @@ func createSimpleSummary(content string) string {
 	return summary
 }
 
+// styleSimpleSummary lays out the sentences of the fallback summary in a
+// summary style. Cheat-sheets get bullets, the closest the fallback can get.
+func styleSimpleSummary(summary, style string) string {
+	sentences := strings.SplitAfter(summary, ". ")
+	if len(sentences) < 2 {
+		return summary
+	}
+	for i := range sentences {
+		sentences[i] = strings.TrimSpace(sentences[i])
+	}
+
+	switch style {
+	case SummaryBullets, SummaryCheatSheet:
+		return "- " + strings.Join(sentences, "\n- ")
+	case SummaryOutline:
+		for i := range sentences {
+			sentences[i] = fmt.Sprintf("%d. %s", i+1, sentences[i])
+		}
+		return strings.Join(sentences, "\n")
+	case SummaryTLDR:
+		return strings.Join(sentences[:2], " ")
+	default:
+		return summary
+	}
+}
+
 // createSimpleFlashcards creates comprehensive flashcards when AI fails
 func createSimpleFlashcards(content string) []FlashcardData {
 	// Split into sentences using multiple delimiters
@@ -369,6 +531,7 @@ func createSimpleFlashcards(content string) []FlashcardData {
 		flashcards = append(flashcards, FlashcardData{
 			Question: question,
 			Answer:   sentence,
+			Source:   sentence,
 		})
 	}
 
@@ -401,6 +564,7 @@ func createSimpleFlashcards(content string) []FlashcardData {
 				flashcards = append(flashcards, FlashcardData{
 					Question: question,
 					Answer:   chunk,
+					Source:   chunk,
 				})
 			}
 		} else {
@@ -415,6 +579,12 @@ func createSimpleFlashcards(content string) []FlashcardData {
 	return flashcards
 }
 
+// sourceExplanation explains a fallback answer by quoting the passage of the
+// note it was taken from
+func sourceExplanation(source string) string {
+	return fmt.Sprintf("The note says: \"%s\"", strings.TrimSpace(source))
+}
+
 // createSimpleQuiz creates comprehensive quiz when AI fails
 func createSimpleQuiz(content string) []QuizData {
 	// Split into sentences using multiple delimiters
@@ -507,9 +677,11 @@ func createSimpleQuiz(content string) []QuizData {
 		}
 		
 		quiz = append(quiz, QuizData{
-			Question: question,
-			Options:  options,
-			Answer:   0, // First option is always correct
+			Question:    question,
+			Options:     options,
+			Answer:      0, // First option is always correct
+			Explanation: sourceExplanation(sentence),
+			Source:      sentence,
 		})
 	}
 
@@ -548,9 +720,11 @@ func createSimpleQuiz(content string) []QuizData {
 				}
 				
 				quiz = append(quiz, QuizData{
-					Question: question,
-					Options:  options,
-					Answer:   0,
+					Question:    question,
+					Options:     options,
+					Answer:      0,
+					Explanation: sourceExplanation(chunk),
+					Source:      chunk,
 				})
 			}
 		} else {
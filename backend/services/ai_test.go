@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testNote = `Photosynthesis is the process by which plants turn light into chemical energy. It takes place in the chloroplasts of plant cells.
+The light-dependent reactions capture energy from sunlight and split water molecules, releasing oxygen.
+The Calvin cycle is part of photosynthesis and uses that energy to fix carbon dioxide into glucose.
+Chlorophyll is a pigment that absorbs mostly blue and red light, which is why leaves look green.
+Plants store the glucose they make as starch, and use it for growth and respiration.
+Factors such as light intensity, temperature and carbon dioxide concentration limit the rate of photosynthesis.`
+
+// useProvider makes p the active provider for the rest of the test
+func useProvider(t *testing.T, p Provider) {
+	t.Helper()
+	providerMu.Lock()
+	previous := activeProvider
+	activeProvider = p
+	providerMu.Unlock()
+	t.Cleanup(func() { SetProvider(previous) })
+}
+
+func TestGenerateSummaryStyles(t *testing.T) {
+	useProvider(t, NewMockProvider(1))
+
+	tests := []struct {
+		style string
+		check func(summary string) bool
+	}{
+		{SummaryStandard, func(s string) bool { return !strings.HasPrefix(s, "- ") }},
+		{SummaryBullets, func(s string) bool { return strings.HasPrefix(s, "- ") }},
+		{SummaryOutline, func(s string) bool { return strings.Contains(s, "\n") }},
+		{SummaryTLDR, func(s string) bool { return !strings.Contains(s, "\n") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			summary, err := GenerateSummary(context.Background(), testNote, SummaryOptions{Style: tt.style})
+			if err != nil {
+				t.Fatalf("GenerateSummary: %v", err)
+			}
+			if summary == "" {
+				t.Fatal("summary is empty")
+			}
+			if !tt.check(summary) {
+				t.Errorf("summary is not in the %s style:\n%s", tt.style, summary)
+			}
+		})
+	}
+}
+
+func TestGenerateSummaryRejectsEmptyContent(t *testing.T) {
+	useProvider(t, NewMockProvider(1))
+
+	if _, err := GenerateSummary(context.Background(), "   ", SummaryOptions{}); err == nil {
+		t.Error("expected an error for empty content")
+	}
+}
+
+func TestGenerateFlashcards(t *testing.T) {
+	useProvider(t, NewMockProvider(1))
+
+	cards, err := GenerateFlashcards(context.Background(), testNote, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("GenerateFlashcards: %v", err)
+	}
+	if len(cards) == 0 {
+		t.Fatal("no flashcards generated")
+	}
+	for _, card := range cards {
+		if card.Question == "" || card.Answer == "" {
+			t.Errorf("incomplete flashcard %+v", card)
+		}
+	}
+
+	limited, err := GenerateFlashcards(context.Background(), testNote, GenerationOptions{Count: 2})
+	if err != nil {
+		t.Fatalf("GenerateFlashcards with count: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("got %d flashcards, want 2", len(limited))
+	}
+}
+
+func TestGenerateQuiz(t *testing.T) {
+	useProvider(t, NewMockProvider(1))
+
+	quiz, err := GenerateQuiz(context.Background(), testNote, QuizOptions{})
+	if err != nil {
+		t.Fatalf("GenerateQuiz: %v", err)
+	}
+	if len(quiz) == 0 {
+		t.Fatal("no questions generated")
+	}
+	for _, q := range quiz {
+		if q.Type != QuestionMultipleChoice {
+			t.Errorf("question type %q, want %q", q.Type, QuestionMultipleChoice)
+		}
+		if err := ValidateQuestion(q); err != nil {
+			t.Errorf("invalid question %q: %v", q.Question, err)
+		}
+	}
+}
+
+func TestGenerateQuizTypes(t *testing.T) {
+	useProvider(t, NewMockProvider(1))
+
+	types := []string{QuestionTrueFalse, QuestionCloze}
+	quiz, err := GenerateQuiz(context.Background(), testNote, QuizOptions{Types: types})
+	if err != nil {
+		t.Fatalf("GenerateQuiz: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, q := range quiz {
+		if q.Type != QuestionTrueFalse && q.Type != QuestionCloze {
+			t.Errorf("unrequested question type %q", q.Type)
+		}
+		if err := ValidateQuestion(q); err != nil {
+			t.Errorf("invalid question %q: %v", q.Question, err)
+		}
+		seen[q.Type] = true
+	}
+	for _, typ := range types {
+		if !seen[typ] {
+			t.Errorf("no %s questions generated", typ)
+		}
+	}
+}
+
+func TestMockProviderIsDeterministic(t *testing.T) {
+	generate := func(seed int64) []QuizData {
+		useProvider(t, NewMockProvider(seed))
+		quiz, err := GenerateQuiz(context.Background(), testNote, QuizOptions{})
+		if err != nil {
+			t.Fatalf("GenerateQuiz: %v", err)
+		}
+		return quiz
+	}
+
+	if first, second := generate(7), generate(7); !reflect.DeepEqual(first, second) {
+		t.Error("the same seed gave different quizzes")
+	}
+}
+
+// stubProvider answers every prompt with reply, or fails with err
+type stubProvider struct {
+	reply string
+	err   error
+}
+
+func (p stubProvider) Name() string {
+	return "stub"
+}
+
+func (p stubProvider) Complete(ctx context.Context, prompt string, opts CompletionOptions) (string, error) {
+	return p.reply, p.err
+}
+
+func TestFallbacks(t *testing.T) {
+	providers := map[string]Provider{
+		"provider error": stubProvider{err: errors.New("unreachable")},
+		"invalid JSON":   stubProvider{reply: "Sorry, I can't help with that."},
+	}
+	for name, provider := range providers {
+		t.Run(name, func(t *testing.T) {
+			useProvider(t, provider)
+			ctx := context.Background()
+
+			summary, err := GenerateSummary(ctx, testNote, SummaryOptions{Style: SummaryBullets})
+			if err != nil {
+				t.Fatalf("GenerateSummary: %v", err)
+			}
+			if !strings.HasPrefix(summary, "- ") {
+				t.Errorf("fallback summary is not in bullets:\n%s", summary)
+			}
+
+			cards, err := GenerateFlashcards(ctx, testNote, GenerationOptions{Count: 3})
+			if err != nil {
+				t.Fatalf("GenerateFlashcards: %v", err)
+			}
+			if len(cards) == 0 || len(cards) > 3 {
+				t.Errorf("got %d fallback flashcards, want 1-3", len(cards))
+			}
+
+			quiz, err := GenerateQuiz(ctx, testNote, QuizOptions{Types: []string{QuestionMultipleChoice, QuestionShortAnswer}})
+			if err != nil {
+				t.Fatalf("GenerateQuiz: %v", err)
+			}
+			if len(quiz) == 0 {
+				t.Fatal("no fallback questions")
+			}
+			for _, q := range quiz {
+				if err := ValidateQuestion(q); err != nil {
+					t.Errorf("invalid fallback question %q: %v", q.Question, err)
+				}
+				if q.Type == QuestionMultipleChoice && !strings.Contains(q.Explanation, q.Source) {
+					t.Errorf("explanation %q does not quote the source %q", q.Explanation, q.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbackStopsWhenCanceled(t *testing.T) {
+	useProvider(t, stubProvider{err: context.Canceled})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := GenerateSummary(ctx, testNote, SummaryOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateSummary error = %v, want context.Canceled", err)
+	}
+	if _, err := GenerateFlashcards(ctx, testNote, GenerationOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateFlashcards error = %v, want context.Canceled", err)
+	}
+	if _, err := GenerateQuiz(ctx, testNote, QuizOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateQuiz error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Passage is a piece of a note used to ground an answer
+type Passage struct {
+	NoteID      int    `json:"note_id"`
+	NoteTitle   string `json:"note_title"`
+	ChunkID     int    `json:"chunk_id,omitempty"`
+	ChunkIndex  int    `json:"chunk_index"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Text        string `json:"text"`
+}
+
+// Answer is a response to a question with the passages it cites
+type Answer struct {
+	Answer    string    `json:"answer"`
+	Citations []Passage `json:"citations"`
+}
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// AnswerQuestion answers question from the given passages, citing the ones
+// it used
+func AnswerQuestion(ctx context.Context, question string, passages []Passage) (Answer, error) {
+	if strings.TrimSpace(question) == "" {
+		return Answer{}, fmt.Errorf("question cannot be empty")
+	}
+	if len(passages) == 0 {
+		return Answer{
+			Answer:    "I couldn't find anything in your notes about that.",
+			Citations: []Passage{},
+		}, nil
+	}
+
+	excerpts := formatPassages(passages)
+	prompt := fmt.Sprintf(`Answer the student's question using only the numbered passages from their notes below. Cite the passages you use with their number in square brackets, like [1]. If the passages do not contain the answer, say that the notes don't cover it.
+
+%s
+
+Question: %s
+
+Answer:`, quoteNotes(excerpts), question)
+
+	provider := CurrentProvider()
+	response, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskAnswer}, nil)
+	response = strings.TrimSpace(response)
+	if err != nil || response == "" {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Answer{}, ctxErr
+		}
+		fmt.Printf("AI provider %s failed for answer: %v\n", provider.Name(), err)
+		return createSimpleAnswer(question, passages), nil
+	}
+
+	return Answer{Answer: response, Citations: citedPassages(response, passages)}, nil
+}
+
+// formatPassages numbers passages for use in a prompt
+func formatPassages(passages []Passage) string {
+	var b strings.Builder
+	for i, passage := range passages {
+		fmt.Fprintf(&b, "[%d] (%s)\n%s\n\n", i+1, passage.NoteTitle, passage.Text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// citedPassages returns the passages referenced as [n] in text, in order of
+// first citation, or all of them when the model didn't cite any
+func citedPassages(text string, passages []Passage) []Passage {
+	seen := make(map[int]bool)
+	var cited []Passage
+	for _, match := range citationPattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > len(passages) || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cited = append(cited, passages[n-1])
+	}
+
+	if len(cited) == 0 {
+		return passages
+	}
+	return cited
+}
+
+// RankPassages orders passages by how many of the question's words they
+// contain and returns at most limit of them
+func RankPassages(question string, passages []Passage, limit int) []Passage {
+	terms := keywords(question)
+	scores := make([]int, len(passages))
+	for i, passage := range passages {
+		scores[i] = overlap(terms, passage.Text)
+	}
+
+	ranked := make([]int, len(passages))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return scores[ranked[a]] > scores[ranked[b]]
+	})
+
+	var result []Passage
+	for _, i := range ranked {
+		if len(result) >= limit {
+			break
+		}
+		result = append(result, passages[i])
+	}
+	return result
+}
+
+// createSimpleAnswer picks the sentences that share the most words with the
+// question when AI fails
+func createSimpleAnswer(question string, passages []Passage) Answer {
+	terms := keywords(question)
+
+	type candidate struct {
+		sentence string
+		passage  int
+		score    int
+	}
+	var candidates []candidate
+	for i, passage := range passages {
+		sentences := strings.FieldsFunc(passage.Text, func(c rune) bool {
+			return c == '.' || c == '!' || c == '?'
+		})
+		for _, sentence := range sentences {
+			sentence = strings.TrimSpace(sentence)
+			if len(sentence) < 20 {
+				continue
+			}
+			if score := overlap(terms, sentence); score > 0 {
+				candidates = append(candidates, candidate{sentence, i, score})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Answer{
+			Answer:    "The most relevant passage in your notes is: " + passages[0].Text,
+			Citations: passages[:1],
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+
+	var sentences []string
+	var citations []Passage
+	cited := make(map[int]bool)
+	for _, c := range candidates[:min(3, len(candidates))] {
+		sentences = append(sentences, c.sentence)
+		if !cited[c.passage] {
+			cited[c.passage] = true
+			citations = append(citations, passages[c.passage])
+		}
+	}
+
+	return Answer{Answer: strings.Join(sentences, ". ") + ".", Citations: citations}
+}
+
+// keywords returns the lowercased words of text longer than three letters,
+// which skips most question words and articles
+func keywords(text string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 3 {
+			terms[word] = true
+		}
+	}
+	return terms
+}
+
+// overlap counts the distinct terms that appear in text
+func overlap(terms map[string]bool, text string) int {
+	found := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if terms[word] {
+			found[word] = true
+		}
+	}
+	return len(found)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is a rough estimate used for token budgeting; it holds up
+// well enough for English text across the models we support
+const charsPerToken = 4
+
+// NoteChunkOptions sizes note chunks to fit within the embedding model input
+var NoteChunkOptions = ChunkOptions{Size: 800, Overlap: 100}
+
+// Chunk is a slice of a larger text with its byte offsets into the source
+type Chunk struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// ChunkOptions controls chunk size and overlap, both in characters
+type ChunkOptions struct {
+	Size    int
+	Overlap int
+}
+
+// EstimateTokens approximates the number of tokens in text
+func EstimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// ChunkText splits text into chunks of at most opts.Size characters,
+// preferring to break at section and paragraph boundaries, then sentences,
+// then words. Consecutive chunks share roughly opts.Overlap characters.
+func ChunkText(text string, opts ChunkOptions) []Chunk {
+	if opts.Size <= 0 {
+		opts.Size = 4000
+	}
+	if opts.Overlap < 0 || opts.Overlap >= opts.Size {
+		opts.Overlap = 0
+	}
+
+	var chunks []Chunk
+	start := 0
+	for start < len(text) {
+		end := start + opts.Size
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			end = breakPoint(text, start, end)
+		}
+
+		if chunk, ok := trimChunk(text, start, end); ok {
+			chunk.Index = len(chunks)
+			chunks = append(chunks, chunk)
+		}
+		if end >= len(text) {
+			break
+		}
+
+		// Step back for the overlap, then forward to the next word start so
+		// chunks never begin mid-word
+		next := end - opts.Overlap
+		if next <= start {
+			next = end
+		}
+		for next < end && !isSpace(text[next]) {
+			next++
+		}
+		for next < len(text) && isSpace(text[next]) {
+			next++
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// breakPoint finds the best place to end a chunk that would otherwise end
+// at limit, searching the second half of the window so chunks stay large
+func breakPoint(text string, start, limit int) int {
+	floor := start + (limit-start)/2
+	window := text[floor:limit]
+
+	for _, sep := range []string{"\n\n", "\n", ". ", "? ", "! ", " "} {
+		if i := strings.LastIndex(window, sep); i != -1 {
+			return floor + i + len(sep)
+		}
+	}
+
+	// No natural boundary, cut at the limit without splitting a rune
+	for limit > start && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return limit
+}
+
+// trimChunk builds a chunk for text[start:end] with surrounding whitespace
+// removed and the offsets adjusted to match
+func trimChunk(text string, start, end int) (Chunk, bool) {
+	for start < end && isSpace(text[start]) {
+		start++
+	}
+	for end > start && isSpace(text[end-1]) {
+		end--
+	}
+	if start == end {
+		return Chunk{}, false
+	}
+	return Chunk{Text: text[start:end], Start: start, End: end}, true
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkText(t *testing.T) {
+	paragraph := strings.Repeat("The mitochondria is the powerhouse of the cell. ", 8)
+	text := strings.Join([]string{paragraph, paragraph, paragraph, paragraph}, "\n\n")
+
+	tests := []struct {
+		name string
+		opts ChunkOptions
+	}{
+		{"no overlap", ChunkOptions{Size: 500}},
+		{"overlap", ChunkOptions{Size: 500, Overlap: 100}},
+		{"small", ChunkOptions{Size: 60, Overlap: 10}},
+		{"overlap too large", ChunkOptions{Size: 200, Overlap: 300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := ChunkText(text, tt.opts)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks, want several", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if chunk.Index != i {
+					t.Errorf("chunk %d has index %d", i, chunk.Index)
+				}
+				if len(chunk.Text) > tt.opts.Size {
+					t.Errorf("chunk %d is %d bytes, over the %d limit", i, len(chunk.Text), tt.opts.Size)
+				}
+				if text[chunk.Start:chunk.End] != chunk.Text {
+					t.Errorf("chunk %d offsets %d-%d don't match its text", i, chunk.Start, chunk.End)
+				}
+				if chunk.Text != strings.TrimSpace(chunk.Text) {
+					t.Errorf("chunk %d has surrounding whitespace", i)
+				}
+				if chunk.Start > 0 && !isSpace(text[chunk.Start-1]) {
+					t.Errorf("chunk %d starts mid-word: %q", i, chunk.Text[:10])
+				}
+				if i > 0 && chunk.Start <= chunks[i-1].Start {
+					t.Errorf("chunk %d does not move forward", i)
+				}
+			}
+			if last := chunks[len(chunks)-1]; last.End != len(strings.TrimRight(text, " ")) {
+				t.Errorf("chunks end at %d, text ends at %d", last.End, len(text))
+			}
+		})
+	}
+}
+
+func TestChunkTextPrefersParagraphs(t *testing.T) {
+	first := strings.Repeat("a ", 150)
+	second := strings.Repeat("b ", 150)
+	chunks := ChunkText(first+"\n\n"+second, ChunkOptions{Size: 400})
+
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	if strings.Contains(chunks[0].Text, "b") || strings.Contains(chunks[1].Text, "a") {
+		t.Error("chunks were not split at the paragraph break")
+	}
+}
+
+func TestChunkTextShortAndEmpty(t *testing.T) {
+	if chunks := ChunkText("  \n\n ", ChunkOptions{Size: 100}); len(chunks) != 0 {
+		t.Errorf("blank text gave %d chunks", len(chunks))
+	}
+
+	chunks := ChunkText("  A short note.  ", ChunkOptions{Size: 100})
+	if len(chunks) != 1 || chunks[0].Text != "A short note." || chunks[0].Start != 2 {
+		t.Errorf("got %+v", chunks)
+	}
+}
+
+func TestChunkTextKeepsRunesWhole(t *testing.T) {
+	text := strings.Repeat("é", 300)
+	for _, chunk := range ChunkText(text, ChunkOptions{Size: 101}) {
+		if !strings.HasPrefix(text[chunk.Start:], "é") || !strings.HasSuffix(chunk.Text, "é") {
+			t.Fatalf("chunk %d splits a rune", chunk.Index)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Concept relation types. A relation reads from its source to its target:
+// "source is a target", "source is part of target", "source causes target"
+// and "source is a prerequisite of target".
+const (
+	RelationIsA          = "is_a"
+	RelationPartOf       = "part_of"
+	RelationCauses       = "causes"
+	RelationPrerequisite = "prerequisite"
+)
+
+// RelationTypes lists every relation type
+var RelationTypes = []string{RelationIsA, RelationPartOf, RelationCauses, RelationPrerequisite}
+
+// relationLabels are how relations are written on exported graphs
+var relationLabels = map[string]string{
+	RelationIsA:          "is a",
+	RelationPartOf:       "part of",
+	RelationCauses:       "causes",
+	RelationPrerequisite: "prerequisite of",
+}
+
+// ConceptData is a concept of a note
+type ConceptData struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RelationData links two concepts by name
+type RelationData struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"` // One of RelationTypes
+}
+
+// ConceptGraph is a set of concepts and the relations between them
+type ConceptGraph struct {
+	Concepts  []ConceptData  `json:"concepts"`
+	Relations []RelationData `json:"relations"`
+}
+
+// conceptGraphSchema describes the structured output expected for a graph
+var conceptGraphSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"concepts": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":        map[string]interface{}{"type": "string"},
+					"description": map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"name", "description"},
+				"additionalProperties": false,
+			},
+		},
+		"relations": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"source": map[string]interface{}{"type": "string"},
+					"target": map[string]interface{}{"type": "string"},
+					"type":   map[string]interface{}{"type": "string", "enum": RelationTypes},
+				},
+				"required":             []string{"source", "target", "type"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"concepts", "relations"},
+	"additionalProperties": false,
+}
+
+// ConceptKey is the name a concept is matched by, so the same concept from
+// different notes is stored once
+func ConceptKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// ExtractConcepts finds the concepts of the given text and how they relate,
+// falling back to the glossary terms and the relations the text spells out
+func ExtractConcepts(ctx context.Context, content string) (ConceptGraph, error) {
+	if strings.TrimSpace(content) == "" {
+		return ConceptGraph{}, fmt.Errorf("content cannot be empty")
+	}
+
+	provider := CurrentProvider()
+
+	prompt := fmt.Sprintf(`Build a concept map of the following text. List the 8-15 key concepts it covers, each with a one-sentence description, and the relations between them. Every relation has one of these types, reading from source to target:
+- is_a: the source is a kind of the target
+- part_of: the source is a part or stage of the target
+- causes: the source causes or leads to the target
+- prerequisite: the source must be understood before the target
+
+Only use concept names from your list in relations, and only include relations the text supports.
+
+Format the response as valid JSON with this exact structure:
+{"concepts": [{"name": "A concept", "description": "What it is"}], "relations": [{"source": "A concept", "target": "Another concept", "type": "part_of"}]}
+
+Text to map:
+%s
+
+Return only the JSON, no additional text:`, quoteNotes(content))
+
+	response, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskConcepts, JSONSchema: conceptGraphSchema}, nil)
+	if err != nil {
+		fmt.Printf("AI provider %s failed for concept map: %v\n", provider.Name(), err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ConceptGraph{}, ctxErr
+		}
+	} else {
+		var graph ConceptGraph
+		if err := json.Unmarshal([]byte(extractJSON(response)), &graph); err != nil {
+			fmt.Printf("JSON parsing failed for concept map from %s: %v\n", provider.Name(), err)
+		} else if graph = cleanGraph(graph); len(graph.Concepts) > 0 {
+			return graph, nil
+		}
+	}
+
+	fmt.Printf("AI concept map unavailable, using pattern fallback\n")
+	return cleanGraph(createSimpleConceptGraph(content)), nil
+}
+
+// cleanGraph drops unnamed and repeated concepts and relations that are
+// unknown, repeated or loop back to their source. Concepts only named in a
+// relation are added to the list.
+func cleanGraph(graph ConceptGraph) ConceptGraph {
+	cleaned := ConceptGraph{Concepts: []ConceptData{}, Relations: []RelationData{}}
+	seen := map[string]bool{}
+	addConcept := func(concept ConceptData) {
+		concept.Name = strings.Join(strings.Fields(concept.Name), " ")
+		concept.Description = strings.TrimSpace(concept.Description)
+		key := ConceptKey(concept.Name)
+		if key == "" || len(concept.Name) > maxTermLength || seen[key] {
+			return
+		}
+		seen[key] = true
+		cleaned.Concepts = append(cleaned.Concepts, concept)
+	}
+	for _, concept := range graph.Concepts {
+		addConcept(concept)
+	}
+
+	seenRelations := map[RelationData]bool{}
+	for _, relation := range graph.Relations {
+		if _, ok := relationLabels[relation.Type]; !ok {
+			continue
+		}
+		source, target := ConceptKey(relation.Source), ConceptKey(relation.Target)
+		if source == "" || target == "" || source == target {
+			continue
+		}
+		key := RelationData{Source: source, Target: target, Type: relation.Type}
+		if seenRelations[key] {
+			continue
+		}
+		seenRelations[key] = true
+		addConcept(ConceptData{Name: relation.Source})
+		addConcept(ConceptData{Name: relation.Target})
+		if !seen[source] || !seen[target] {
+			continue
+		}
+		cleaned.Relations = append(cleaned.Relations, RelationData{
+			Source: strings.Join(strings.Fields(relation.Source), " "),
+			Target: strings.Join(strings.Fields(relation.Target), " "),
+			Type:   relation.Type,
+		})
+	}
+	return cleaned
+}
+
+// relationCues match the words between two concepts in a sentence that
+// relate them. Reversed cues relate the second concept to the first.
+var relationCues = []struct {
+	pattern  *regexp.Regexp
+	relation string
+	reversed bool
+}{
+	{regexp.MustCompile(`^(is|are) (a |an |one )?(part|component|member|stage|step|phase) of( the| a| an)?$`), RelationPartOf, false},
+	{regexp.MustCompile(`^(consists of|consist of|contains|contain|includes|include|comprises|comprise|is made up of|are made up of)( the| a| an)?$`), RelationPartOf, true},
+	{regexp.MustCompile(`^(is|are)( a| an)?( (type|kind|form|class|example) of)?( the)?$`), RelationIsA, false},
+	{regexp.MustCompile(`^(causes|cause|leads to|lead to|results in|result in|produces|produce|triggers|trigger)( the| a| an)?$`), RelationCauses, false},
+	{regexp.MustCompile(`^(is|are) (caused|produced|triggered) by( the| a| an)?$`), RelationCauses, true},
+	{regexp.MustCompile(`^(requires|require|depends on|depend on|builds on|build on|needs|need)( the| a| an)?$`), RelationPrerequisite, true},
+	{regexp.MustCompile(`^(is|are) (a )?(prerequisite|required|needed) (for|of|to)( the| a| an)?$`), RelationPrerequisite, false},
+}
+
+// createSimpleConceptGraph builds a concept map without a model. The
+// concepts are the glossary fallback's terms, and two concepts are related
+// when a sentence mentions them with a relation cue between them, as in
+// "the Calvin cycle is part of photosynthesis".
+func createSimpleConceptGraph(content string) ConceptGraph {
+	graph := ConceptGraph{Concepts: []ConceptData{}, Relations: []RelationData{}}
+	terms := createSimpleGlossary(content)
+	if len(terms) == 0 {
+		return graph
+	}
+
+	patterns := make([]*regexp.Regexp, len(terms))
+	for i, term := range terms {
+		graph.Concepts = append(graph.Concepts, ConceptData{Name: term.Term, Description: term.Definition})
+		patterns[i] = regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term.Term) + `\b`)
+	}
+
+	type mention struct {
+		concept    int
+		start, end int
+	}
+	for _, sentence := range strings.FieldsFunc(content, func(c rune) bool {
+		return c == '.' || c == '!' || c == '?'
+	}) {
+		// Mentions in the order they appear in the sentence
+		var mentions []mention
+		for i, pattern := range patterns {
+			for _, loc := range pattern.FindAllStringIndex(sentence, -1) {
+				mentions = append(mentions, mention{i, loc[0], loc[1]})
+			}
+		}
+		sort.Slice(mentions, func(i, j int) bool {
+			return mentions[i].start < mentions[j].start
+		})
+
+		for i := 0; i+1 < len(mentions); i++ {
+			first, second := mentions[i], mentions[i+1]
+			if second.start < first.end || first.concept == second.concept {
+				continue
+			}
+			between := strings.ToLower(strings.Join(strings.Fields(sentence[first.end:second.start]), " "))
+			for _, cue := range relationCues {
+				if !cue.pattern.MatchString(between) {
+					continue
+				}
+				source, target := terms[first.concept].Term, terms[second.concept].Term
+				if cue.reversed {
+					source, target = target, source
+				}
+				graph.Relations = append(graph.Relations, RelationData{Source: source, Target: target, Type: cue.relation})
+				break
+			}
+		}
+	}
+	return graph
+}
+
+// MermaidGraph writes a concept graph as a Mermaid flowchart
+func MermaidGraph(graph ConceptGraph) string {
+	ids := graphNodeIDs(graph)
+	escape := strings.NewReplacer(`"`, "#quot;", "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, concept := range graph.Concepts {
+		fmt.Fprintf(&b, "    n%d[\"%s\"]\n", i, escape.Replace(concept.Name))
+	}
+	for _, relation := range graph.Relations {
+		source, okSource := ids[ConceptKey(relation.Source)]
+		target, okTarget := ids[ConceptKey(relation.Target)]
+		if okSource && okTarget {
+			fmt.Fprintf(&b, "    n%d -->|%s| n%d\n", source, relationLabels[relation.Type], target)
+		}
+	}
+	return b.String()
+}
+
+// DOTGraph writes a concept graph in the Graphviz DOT language
+func DOTGraph(graph ConceptGraph) string {
+	ids := graphNodeIDs(graph)
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("digraph concepts {\n    rankdir=LR;\n")
+	for i, concept := range graph.Concepts {
+		fmt.Fprintf(&b, "    n%d [label=\"%s\"];\n", i, escape.Replace(concept.Name))
+	}
+	for _, relation := range graph.Relations {
+		source, okSource := ids[ConceptKey(relation.Source)]
+		target, okTarget := ids[ConceptKey(relation.Target)]
+		if okSource && okTarget {
+			fmt.Fprintf(&b, "    n%d -> n%d [label=\"%s\"];\n", source, target, relationLabels[relation.Type])
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// graphNodeIDs numbers the concepts of a graph by their keys
+func graphNodeIDs(graph ConceptGraph) map[string]int {
+	ids := make(map[string]int, len(graph.Concepts))
+	for i, concept := range graph.Concepts {
+		ids[ConceptKey(concept.Name)] = i
+	}
+	return ids
+}
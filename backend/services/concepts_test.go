@@ -0,0 +1,133 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateSimpleConceptGraph(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		relations []RelationData
+	}{
+		{
+			name:      "part of",
+			content:   testNote,
+			relations: []RelationData{{Source: "Calvin cycle", Target: "photosynthesis", Type: RelationPartOf}},
+		},
+		{
+			name:    "reversed cues",
+			content: "The Calvin cycle is part of photosynthesis. Photosynthesis requires chlorophyll. The Calvin cycle needs chlorophyll. Chlorophyll is a pigment. Photosynthesis produces glucose. Glucose is stored as starch.",
+			relations: []RelationData{
+				{Source: "Calvin cycle", Target: "photosynthesis", Type: RelationPartOf},
+				{Source: "chlorophyll", Target: "photosynthesis", Type: RelationPrerequisite},
+				{Source: "chlorophyll", Target: "Calvin cycle", Type: RelationPrerequisite},
+				{Source: "photosynthesis", Target: "glucose", Type: RelationCauses},
+			},
+		},
+		{
+			// Both sentences give the same relation; "shapes" is no cue
+			name:    "caused by",
+			content: "Erosion is caused by wind. Wind causes erosion. Erosion shapes valleys. Wind is strong near valleys.",
+			relations: []RelationData{
+				{Source: "wind", Target: "Erosion", Type: RelationCauses},
+				{Source: "wind", Target: "Erosion", Type: RelationCauses},
+			},
+		},
+		{
+			name:    "is a",
+			content: "A mitochondrion is an organelle. Every organelle has a membrane. The mitochondrion makes energy.",
+			relations: []RelationData{
+				{Source: "mitochondrion", Target: "organelle", Type: RelationIsA},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := createSimpleConceptGraph(tt.content)
+			if !reflect.DeepEqual(graph.Relations, tt.relations) {
+				t.Errorf("relations = %+v, want %+v", graph.Relations, tt.relations)
+			}
+		})
+	}
+}
+
+func TestCleanGraph(t *testing.T) {
+	graph := cleanGraph(ConceptGraph{
+		Concepts: []ConceptData{
+			{Name: " Calvin  cycle ", Description: " Fixes carbon. "},
+			{Name: "calvin cycle", Description: "Repeated."},
+			{Name: "   "},
+			{Name: "Photosynthesis"},
+		},
+		Relations: []RelationData{
+			{Source: "Calvin cycle", Target: "photosynthesis", Type: RelationPartOf},
+			{Source: "calvin  cycle", Target: "Photosynthesis", Type: RelationPartOf},
+			{Source: "Calvin cycle", Target: "CALVIN CYCLE", Type: RelationIsA},
+			{Source: "Calvin cycle", Target: "photosynthesis", Type: "related_to"},
+			{Source: "", Target: "photosynthesis", Type: RelationCauses},
+			{Source: "Light", Target: "Photosynthesis", Type: RelationPrerequisite},
+		},
+	})
+
+	want := ConceptGraph{
+		Concepts: []ConceptData{
+			{Name: "Calvin cycle", Description: "Fixes carbon."},
+			{Name: "Photosynthesis"},
+			{Name: "Light"},
+		},
+		Relations: []RelationData{
+			{Source: "Calvin cycle", Target: "photosynthesis", Type: RelationPartOf},
+			{Source: "Light", Target: "Photosynthesis", Type: RelationPrerequisite},
+		},
+	}
+	if !reflect.DeepEqual(graph, want) {
+		t.Errorf("cleanGraph = %+v, want %+v", graph, want)
+	}
+}
+
+func TestGraphEscaping(t *testing.T) {
+	graph := ConceptGraph{
+		Concepts: []ConceptData{
+			{Name: `The "light" reactions`},
+			{Name: `C:\path`},
+			{Name: "Two\nlines"},
+		},
+		Relations: []RelationData{
+			{Source: `the "light" reactions`, Target: "two\nlines", Type: RelationCauses},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format func(ConceptGraph) string
+		want   []string
+	}{
+		{"mermaid", MermaidGraph, []string{
+			"graph LR",
+			`    n0["The #quot;light#quot; reactions"]`,
+			`    n1["C:\path"]`,
+			`    n2["Two lines"]`,
+			"    n0 -->|causes| n2",
+		}},
+		{"dot", DOTGraph, []string{
+			"digraph concepts {",
+			"    rankdir=LR;",
+			`    n0 [label="The \"light\" reactions"];`,
+			`    n1 [label="C:\\path"];`,
+			`    n2 [label="Two lines"];`,
+			`    n0 -> n2 [label="causes"];`,
+			"}",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(strings.TrimSuffix(tt.format(graph), "\n"), "\n")
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
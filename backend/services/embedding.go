@@ -1,79 +1,104 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+	"unicode/utf8"
 
 	"github.com/pgvector/pgvector-go"
 )
 
-type EmbeddingRequest struct {
-	Inputs string `json:"inputs"`
-}
+// maxEmbeddingChars keeps input within the embedding model's token limit;
+// longer texts should be split with NoteChunkOptions first
+const maxEmbeddingChars = 1000
 
-type EmbeddingResponse []float32
+// EmbeddingDimensions matches the vector(384) columns in the database
+const EmbeddingDimensions = 384
 
-// GenerateEmbedding creates an embedding vector for the given text
-func GenerateEmbedding(text string) (pgvector.Vector, error) {
-	// Truncate text if too long (most models have limits)
-	if len(text) > 512 {
-		text = text[:512]
-	}
+// embeddingBatchSize caps the number of texts sent in one embedding request
+const embeddingBatchSize = 32
+
+// ErrEmbeddingDimensions is returned when the embedding model produces
+// vectors that don't fit the database columns
+var ErrEmbeddingDimensions = errors.New("embedding model returned the wrong number of dimensions")
 
-	// Use HuggingFace's all-MiniLM-L6-v2 model for embeddings
-	embedding, err := callHuggingFaceEmbedding(text)
+// GenerateEmbedding creates an embedding vector for the given text
+func GenerateEmbedding(ctx context.Context, text string) (pgvector.Vector, error) {
+	embeddings, err := GenerateEmbeddings(ctx, []string{text})
 	if err != nil {
-		return pgvector.NewVector([]float32{}), fmt.Errorf("failed to generate embedding: %w", err)
+		return pgvector.NewVector([]float32{}), err
 	}
-
-	return pgvector.NewVector(embedding), nil
+	return embeddings[0], nil
 }
 
-// callHuggingFaceEmbedding makes a request to HuggingFace embedding API
-func callHuggingFaceEmbedding(text string) ([]float32, error) {
-	reqBody := EmbeddingRequest{
-		Inputs: text,
+// GenerateEmbeddings creates an embedding vector for each text, in batches
+// when the provider supports it
+func GenerateEmbeddings(ctx context.Context, texts []string) ([]pgvector.Vector, error) {
+	// Use the provider's own embedding model when it has one, otherwise
+	// HuggingFace's all-MiniLM-L6-v2
+	fallback := NewHuggingFaceProvider(os.Getenv("HUGGINGFACE_API_KEY"))
+	embedder, ok := CurrentProvider().(Embedder)
+	if !ok {
+		embedder = fallback
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
+	vectors := make([]pgvector.Vector, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		batch := make([]string, 0, embeddingBatchSize)
+		for _, text := range texts[start:min(start+embeddingBatchSize, len(texts))] {
+			batch = append(batch, truncateForEmbedding(text))
+		}
+
+		embeddings, err := embedBatch(ctx, embedder, batch)
+		if errors.Is(err, ErrNoEmbeddingModel) {
+			embedder = fallback
+			embeddings, err = embedBatch(ctx, embedder, batch)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate embedding: %w", err)
+		}
+
+		for _, embedding := range embeddings {
+			if len(embedding) != EmbeddingDimensions {
+				return nil, fmt.Errorf("%w: got %d, the database stores %d", ErrEmbeddingDimensions, len(embedding), EmbeddingDimensions)
+			}
+			vectors = append(vectors, pgvector.NewVector(embedding))
+		}
 	}
 
-	req, err := http.NewRequest("POST", "https://api-inference.huggingface.co/models/sentence-transformers/all-MiniLM-L6-v2", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
+	return vectors, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	
-	// Add HuggingFace API key if available
-	if apiKey := os.Getenv("HUGGINGFACE_API_KEY"); apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+apiKey)
+// embedBatch embeds texts in one request when the embedder supports it and
+// one at a time otherwise
+func embedBatch(ctx context.Context, embedder Embedder, texts []string) ([][]float32, error) {
+	if batcher, ok := embedder.(BatchEmbedder); ok {
+		return batcher.EmbedBatch(ctx, texts)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := embedder.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
 	}
-	defer resp.Body.Close()
+	return embeddings, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// truncateForEmbedding cuts text to maxEmbeddingChars on a rune boundary
+func truncateForEmbedding(text string) string {
+	if len(text) <= maxEmbeddingChars {
+		return text
 	}
-
-	var embedding EmbeddingResponse
-	if err := json.Unmarshal(body, &embedding); err != nil {
-		return nil, err
+	end := maxEmbeddingChars
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
 	}
-
-	return embedding, nil
+	return text[:end]
 }
 
 // CalculateSimilarity calculates cosine similarity between two vectors
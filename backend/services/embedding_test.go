@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGenerateEmbeddingsBatches(t *testing.T) {
+	server := newOpenAIServer(t, "")
+	server.dimensions = EmbeddingDimensions
+	useProvider(t, NewOpenAIProvider(server.URL+"/v1", "", "qwen", "bge-small"))
+
+	texts := make([]string, embeddingBatchSize+5)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("passage %d", i)
+	}
+	embeddings, err := GenerateEmbeddings(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("GenerateEmbeddings: %v", err)
+	}
+	if len(embeddings) != len(texts) {
+		t.Fatalf("got %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+	if n := len(server.embeds); n != 2 {
+		t.Errorf("sent %d embedding requests, want 2", n)
+	}
+	if first := embeddings[embeddingBatchSize].Slice()[0]; first != 0 {
+		t.Errorf("the second batch starts with the embedding of text %v", first)
+	}
+}
+
+func TestGenerateEmbeddingsChecksDimensions(t *testing.T) {
+	server := newOpenAIServer(t, "")
+	server.dimensions = 768
+	useProvider(t, NewOpenAIProvider(server.URL+"/v1", "", "qwen", "nomic-embed-text"))
+
+	_, err := GenerateEmbedding(context.Background(), "leaf")
+	if !errors.Is(err, ErrEmbeddingDimensions) {
+		t.Errorf("error = %v, want ErrEmbeddingDimensions", err)
+	}
+}
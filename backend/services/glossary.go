@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxGlossaryTerms caps how many terms the fallback extracts
+const maxGlossaryTerms = 12
+
+// maxTermWords is the longest phrase the fallback considers a term
+const maxTermWords = 3
+
+// maxTermLength matches the terms.term column
+const maxTermLength = 255
+
+// glossarySchema describes the structured output expected for a glossary
+var glossarySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"terms": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"term":       map[string]interface{}{"type": "string"},
+					"definition": map[string]interface{}{"type": "string"},
+					"source":     map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"term", "definition", "source"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"terms"},
+	"additionalProperties": false,
+}
+
+// ExtractGlossary finds the key terms of the given text with a definition
+// of each, falling back to ranking the text's phrases by TF-IDF
+func ExtractGlossary(ctx context.Context, content string) ([]TermData, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("content cannot be empty")
+	}
+
+	provider := CurrentProvider()
+	structured := supportsStructuredOutput(provider)
+
+	format := `[
+  {"term": "A key term", "definition": "What the term means, in one or two sentences", "source": "A sentence copied exactly from the text"},
+  {"term": "Another key term", "definition": "What it means", "source": "A sentence copied exactly from the text"}
+]`
+	if structured {
+		format = fmt.Sprintf(`{"terms": %s}`, format)
+	}
+	prompt := fmt.Sprintf(`Extract the 8-12 most important key terms from the following text: subject-specific vocabulary, named concepts, processes and people a student must know, not general words. For each, give a definition of one or two sentences based on the text and the sentence that defines or explains the term, copied word for word from the text.
+
+Format the response as valid JSON with this exact structure:
+%s
+
+Text to extract key terms from:
+%s
+
+Return only the JSON, no additional text:`, format, quoteNotes(content))
+
+	response, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskGlossary, JSONSchema: glossarySchema}, nil)
+	if err != nil {
+		fmt.Printf("AI provider %s failed for glossary: %v\n", provider.Name(), err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+	} else {
+		var terms []TermData
+		if err := decodeGenerated(response, structured, "terms", &terms); err != nil {
+			fmt.Printf("JSON parsing failed for glossary from %s: %v\n", provider.Name(), err)
+		} else if terms = cleanTerms(content, terms); len(terms) > 0 {
+			return terms, nil
+		}
+	}
+
+	fmt.Printf("AI glossary unavailable, using TF-IDF fallback\n")
+	return cleanTerms(content, createSimpleGlossary(content)), nil
+}
+
+// cleanTerms drops terms without a definition, overlong terms and repeated
+// terms, and locates the sources of the rest in content
+func cleanTerms(content string, terms []TermData) []TermData {
+	seen := map[string]bool{}
+	cleaned := make([]TermData, 0, len(terms))
+	for _, term := range terms {
+		term.Term = strings.TrimSpace(term.Term)
+		term.Definition = strings.TrimSpace(term.Definition)
+		key := strings.ToLower(term.Term)
+		if key == "" || len(term.Term) > maxTermLength || term.Definition == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, withTermSource(content, term))
+	}
+	return cleaned
+}
+
+// definitionPattern matches a sentence that opens by defining its subject
+var definitionPattern = regexp.MustCompile(`(?i)^(?:the |a |an )?([\pL\pN][\pL\pN '-]{2,60}?)\s+(?:is|are|refers to|means|describes|consists of)\b`)
+
+// termCandidate is a phrase the fallback considers as a term
+type termCandidate struct {
+	form       string // How the phrase is written in the text
+	midForm    bool   // Whether form was taken from the middle of a sentence
+	words      int
+	count      int // Occurrences in the whole text
+	sentences  int // Sentences the phrase occurs in
+	firstIndex int // Order of first appearance, to break ties
+}
+
+// createSimpleGlossary picks key terms without a model. Candidate terms are
+// runs of up to three words without stop words, a rough stand-in for noun
+// phrases, that recur or are defined. Each sentence counts as a document for
+// TF-IDF, so phrases that recur in a few sentences rank above those spread
+// over the whole text, and longer phrases and phrases the text defines are
+// preferred. A term's definition is the sentence that defines it, or else
+// the first that uses it.
+func createSimpleGlossary(content string) []TermData {
+	var sentences []string
+	for _, sentence := range strings.FieldsFunc(content, func(c rune) bool {
+		return c == '.' || c == '!' || c == '?'
+	}) {
+		if sentence = strings.TrimSpace(sentence); len(sentence) > 20 {
+			sentences = append(sentences, sentence)
+		}
+	}
+	if len(sentences) == 0 {
+		return []TermData{}
+	}
+
+	// definitions maps the subject of each defining sentence to the sentence
+	definitions := map[string]string{}
+	for _, sentence := range sentences {
+		if match := definitionPattern.FindStringSubmatch(sentence); match != nil {
+			if key := strings.ToLower(match[1]); definitions[key] == "" {
+				definitions[key] = sentence
+			}
+		}
+	}
+
+	candidates := map[string]*termCandidate{}
+	for _, sentence := range sentences {
+		inSentence := map[string]bool{}
+		for _, run := range termRuns(sentence) {
+			for size := 1; size <= maxTermWords; size++ {
+				for i := 0; i+size <= len(run); i++ {
+					words := run[i : i+size]
+					form := strings.Join(words, " ")
+					key := strings.ToLower(form)
+					candidate, ok := candidates[key]
+					if !ok {
+						candidate = &termCandidate{form: form, words: size, firstIndex: len(candidates)}
+						candidates[key] = candidate
+					}
+					// Prefer how the phrase is written mid-sentence, where
+					// capitals mean something
+					if mid := !strings.HasPrefix(sentence, words[0]); mid && !candidate.midForm {
+						candidate.form, candidate.midForm = form, true
+					}
+					candidate.count++
+					if !inSentence[key] {
+						inSentence[key] = true
+						candidate.sentences++
+					}
+				}
+			}
+		}
+	}
+
+	type scored struct {
+		key   string
+		score float64
+	}
+	var ranked []scored
+	for key, candidate := range candidates {
+		// A phrase used once is rarely a key term unless the text defines it
+		if candidate.count < 2 && definitions[key] == "" {
+			continue
+		}
+		idf := math.Log(1 + float64(len(sentences))/float64(candidate.sentences))
+		score := float64(candidate.count) * idf * (1 + 0.5*float64(candidate.words-1))
+		if definitions[key] != "" {
+			score *= 2
+		}
+		ranked = append(ranked, scored{key, score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return candidates[ranked[i].key].firstIndex < candidates[ranked[j].key].firstIndex
+	})
+
+	// Skip phrases overlapping a better ranked term
+	terms := []TermData{}
+	var chosen []string
+	for _, r := range ranked {
+		if len(terms) >= maxGlossaryTerms {
+			break
+		}
+		overlaps := false
+		for _, key := range chosen {
+			if strings.Contains(" "+key+" ", " "+r.key+" ") || strings.Contains(" "+r.key+" ", " "+key+" ") {
+				overlaps = true
+				break
+			}
+		}
+		if overlaps {
+			continue
+		}
+
+		form := candidates[r.key].form
+		sentence := definitions[r.key]
+		if sentence == "" {
+			sentence = firstSentenceWith(sentences, form)
+		}
+		chosen = append(chosen, r.key)
+		terms = append(terms, TermData{Term: form, Definition: sentence + ".", Source: sentence})
+	}
+	return terms
+}
+
+// termRuns splits a sentence into runs of words that can be part of a
+// term. Stop words and punctuation end a run.
+func termRuns(sentence string) [][]string {
+	isPunct := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}
+
+	var runs [][]string
+	var run []string
+	flush := func() {
+		if len(run) > 0 {
+			runs = append(runs, run)
+			run = nil
+		}
+	}
+	for _, word := range strings.Fields(sentence) {
+		trimmed := strings.TrimFunc(word, isPunct)
+		if !isTermWord(trimmed) || !strings.HasPrefix(word, trimmed) {
+			flush()
+		}
+		if isTermWord(trimmed) {
+			run = append(run, trimmed)
+		}
+		if !strings.HasSuffix(word, trimmed) {
+			flush()
+		}
+	}
+	flush()
+	return runs
+}
+
+// isTermWord reports whether a word can be part of a term
+func isTermWord(word string) bool {
+	if len(word) < 3 || stopWords[strings.ToLower(word)] {
+		return false
+	}
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstSentenceWith returns the first sentence that mentions term
+func firstSentenceWith(sentences []string, term string) string {
+	pattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
+	for _, sentence := range sentences {
+		if pattern.MatchString(sentence) {
+			return sentence
+		}
+	}
+	return sentences[0]
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateSimpleGlossary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		terms   []string
+		sources map[string]string // Term to the sentence it should be drawn from
+	}{
+		{
+			name:    "ranking",
+			content: testNote,
+			terms:   []string{"photosynthesis", "Calvin cycle", "carbon dioxide", "Chlorophyll", "light", "energy", "plants", "glucose"},
+		},
+		{
+			name:    "overlap",
+			content: "The cell membrane controls what enters the cell. The cell membrane is made of lipids. Proteins in the cell membrane carry ions.",
+			terms:   []string{"cell membrane"},
+		},
+		{
+			name:    "definition source",
+			content: "Mitochondria produce energy for the cell. Later the text says mitochondria matter. Mitochondria are the powerhouse of the cell.",
+			terms:   []string{"mitochondria", "cell"},
+			sources: map[string]string{
+				"mitochondria": "Mitochondria are the powerhouse of the cell",
+				"cell":         "Mitochondria produce energy for the cell",
+			},
+		},
+		{
+			name:    "first use source",
+			content: "Osmosis is the movement of water across a membrane. Osmosis needs a membrane. Cells rely on osmosis daily.",
+			terms:   []string{"osmosis", "membrane"},
+			sources: map[string]string{
+				"osmosis":  "Osmosis is the movement of water across a membrane",
+				"membrane": "Osmosis is the movement of water across a membrane",
+			},
+		},
+		{
+			name:    "no sentences",
+			content: "Short. Tiny.",
+			terms:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			glossary := createSimpleGlossary(tt.content)
+			terms := []string{}
+			for _, term := range glossary {
+				terms = append(terms, term.Term)
+				if term.Definition != term.Source+"." {
+					t.Errorf("%q is defined as %q, want its source %q", term.Term, term.Definition, term.Source)
+				}
+				if want, ok := tt.sources[term.Term]; ok && term.Source != want {
+					t.Errorf("%q comes from %q, want %q", term.Term, term.Source, want)
+				}
+			}
+			if !reflect.DeepEqual(terms, tt.terms) {
+				t.Errorf("terms = %q, want %q", terms, tt.terms)
+			}
+		})
+	}
+}
+
+func TestTermRuns(t *testing.T) {
+	tests := []struct {
+		sentence string
+		want     [][]string
+	}{
+		{"The Calvin cycle, part of photosynthesis, fixes carbon (CO2) in plants", [][]string{{"Calvin", "cycle"}, {"part"}, {"photosynthesis"}, {"fixes", "carbon"}, {"CO2"}, {"plants"}}},
+		{"Light-dependent reactions split water: they release oxygen", [][]string{{"Light-dependent", "reactions", "split", "water"}, {"release", "oxygen"}}},
+		{"It was all of them in 2024", nil},
+	}
+	for _, tt := range tests {
+		if got := termRuns(tt.sentence); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("termRuns(%q) = %q, want %q", tt.sentence, got, tt.want)
+		}
+	}
+}
+
+func TestCleanTerms(t *testing.T) {
+	content := "Osmosis is the movement of water across a membrane."
+	terms := cleanTerms(content, []TermData{
+		{Term: " Osmosis ", Definition: " Movement of water. ", Source: "Osmosis is the movement of water across a membrane"},
+		{Term: "osmosis", Definition: "Repeated with other capitals."},
+		{Term: "membrane", Definition: ""},
+		{Term: "", Definition: "No term."},
+		{Term: strings.Repeat("long ", maxTermLength), Definition: "Too long."},
+		{Term: "water", Definition: "Not quoted from the note.", Source: "Water is wet"},
+	})
+
+	if len(terms) != 2 || terms[0].Term != "Osmosis" || terms[1].Term != "water" {
+		t.Fatalf("got %+v, want Osmosis and water", terms)
+	}
+	if terms[0].Definition != "Movement of water." {
+		t.Errorf("definition = %q, want it trimmed", terms[0].Definition)
+	}
+	if span := terms[0].Span; span == nil || content[span.Start:span.End] != terms[0].Source {
+		t.Errorf("span = %+v, want the source's place in the note", span)
+	}
+	if terms[1].Span != nil {
+		t.Errorf("a source missing from the note got span %+v", terms[1].Span)
+	}
+}
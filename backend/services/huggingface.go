@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const huggingFaceBaseURL = "https://api-inference.huggingface.co/models"
+
+type HuggingFaceRequest struct {
+	Inputs string `json:"inputs"`
+}
+
+type HuggingFaceResponse struct {
+	GeneratedText string `json:"generated_text"`
+}
+
+type EmbeddingRequest struct {
+	Inputs interface{} `json:"inputs"` // A text, or a list of texts
+}
+
+type EmbeddingResponse []float32
+
+// HuggingFaceProvider talks to the HuggingFace Inference API, trying a list
+// of models per task until one of them answers
+type HuggingFaceProvider struct {
+	APIKey     string
+	BaseURL    string
+	Models     map[string][]string
+	EmbedModel string
+	Client     *http.Client
+}
+
+// NewHuggingFaceProvider creates a provider with the default model lists
+func NewHuggingFaceProvider(apiKey string) *HuggingFaceProvider {
+	return &HuggingFaceProvider{
+		APIKey:  apiKey,
+		BaseURL: huggingFaceBaseURL,
+		Models: map[string][]string{
+			TaskSummary: {
+				"facebook/bart-large-cnn",
+				"google/pegasus-xsum",
+				"microsoft/DialoGPT-medium",
+			},
+			TaskFlashcards: {
+				"microsoft/DialoGPT-medium",
+				"facebook/bart-large-cnn",
+				"google/pegasus-xsum",
+			},
+			TaskQuiz: {
+				"microsoft/DialoGPT-medium",
+				"facebook/bart-large-cnn",
+				"google/pegasus-xsum",
+			},
+			TaskAnswer: {
+				"google/flan-t5-large",
+				"microsoft/DialoGPT-medium",
+			},
+			TaskTutor: {
+				"microsoft/DialoGPT-medium",
+				"google/flan-t5-large",
+			},
+			TaskGrading: {
+				"google/flan-t5-large",
+			},
+			TaskGlossary: {
+				"google/flan-t5-large",
+				"microsoft/DialoGPT-medium",
+			},
+			TaskConcepts: {
+				"google/flan-t5-large",
+				"microsoft/DialoGPT-medium",
+			},
+		},
+		EmbedModel: "sentence-transformers/all-MiniLM-L6-v2",
+		Client:     &http.Client{},
+	}
+}
+
+func (p *HuggingFaceProvider) Name() string {
+	return "huggingface"
+}
+
+// Complete sends the prompt to each candidate model in turn and returns the
+// first non-empty generation
+func (p *HuggingFaceProvider) Complete(ctx context.Context, prompt string, opts CompletionOptions) (string, error) {
+	models := p.Models[opts.Task]
+	if opts.Model != "" {
+		models = []string{opts.Model}
+	}
+	if len(models) == 0 {
+		models = p.Models[TaskSummary]
+	}
+
+	lastErr := fmt.Errorf("no response from HuggingFace")
+	for _, model := range models {
+		text, err := p.call(ctx, model, prompt)
+		if err != nil {
+			fmt.Printf("HuggingFace API failed for %s with model %s: %v\n", opts.Task, model, err)
+			lastErr = err
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			continue
+		}
+
+		if strings.TrimSpace(text) != "" {
+			fmt.Printf("Successfully generated %s using model %s\n", opts.Task, model)
+			return text, nil
+		}
+	}
+
+	return "", lastErr
+}
+
+// call makes a request to a single HuggingFace model
+func (p *HuggingFaceProvider) call(ctx context.Context, model, prompt string) (string, error) {
+	reqBody := HuggingFaceRequest{
+		Inputs: prompt,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s", p.BaseURL, model), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Add HuggingFace API key if available
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check for HTTP errors
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("HuggingFace API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var hfResp []HuggingFaceResponse
+	if err := json.Unmarshal(body, &hfResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if len(hfResp) > 0 {
+		return hfResp[0].GeneratedText, nil
+	}
+
+	return "", fmt.Errorf("no response from HuggingFace")
+}
+
+// Embed makes a request to the HuggingFace embedding model
+func (p *HuggingFaceProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	var embedding EmbeddingResponse
+	if err := p.embed(ctx, text, &embedding); err != nil {
+		return nil, err
+	}
+	return embedding, nil
+}
+
+// EmbedBatch embeds all texts with one request to the embedding model
+func (p *HuggingFaceProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	if err := p.embed(ctx, texts, &embeddings); err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings from HuggingFace for %d texts", len(embeddings), len(texts))
+	}
+	return embeddings, nil
+}
+
+// embed sends inputs to the embedding model and decodes the reply into out
+func (p *HuggingFaceProvider) embed(ctx context.Context, inputs interface{}, out interface{}) error {
+	reqBody := EmbeddingRequest{
+		Inputs: inputs,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s", p.BaseURL, p.EmbedModel), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Add HuggingFace API key if available
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+// MockProvider returns deterministic output without touching the network.
+// Results depend only on the seed and the input, so local development,
+// demos and integration tests get stable data.
+type MockProvider struct {
+	Seed int64
+}
+
+// NewMockProvider creates an offline provider seeded with seed
+func NewMockProvider(seed int64) *MockProvider {
+	return &MockProvider{Seed: seed}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// SupportsStructuredOutput reports that responses always match the schema
+func (p *MockProvider) SupportsStructuredOutput() bool {
+	return true
+}
+
+// Complete builds the response from the notes quoted in the prompt with the
+// same heuristics used when a real provider is unavailable
+func (p *MockProvider) Complete(ctx context.Context, prompt string, opts CompletionOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	source := quotedNotes(prompt)
+	rng := p.rand(opts.Task, source)
+
+	switch opts.Task {
+	case TaskSummary:
+		return styleSimpleSummary(createSimpleSummary(source), promptSummaryStyle(prompt)), nil
+	case TaskFlashcards:
+		flashcards := createSimpleFlashcards(source)
+		rng.Shuffle(len(flashcards), func(i, j int) {
+			flashcards[i], flashcards[j] = flashcards[j], flashcards[i]
+		})
+		return marshalMock("flashcards", flashcards)
+	case TaskQuiz:
+		quiz := createSimpleQuiz(source)
+		for i := range quiz {
+			correct := quiz[i].Options[quiz[i].Answer]
+			rng.Shuffle(len(quiz[i].Options), func(a, b int) {
+				quiz[i].Options[a], quiz[i].Options[b] = quiz[i].Options[b], quiz[i].Options[a]
+			})
+			for j, option := range quiz[i].Options {
+				if option == correct {
+					quiz[i].Answer = j
+					break
+				}
+			}
+		}
+		if types := promptQuestionTypes(prompt); len(types) > 0 {
+			quiz = convertSimpleQuiz(quiz, types)
+		}
+		return marshalMock("quiz", quiz)
+	case TaskGlossary:
+		return marshalMock("terms", createSimpleGlossary(source))
+	case TaskConcepts:
+		data, err := json.Marshal(createSimpleConceptGraph(source))
+		return string(data), err
+	default:
+		return fmt.Sprintf("Mock response: %s", createSimpleSummary(source)), nil
+	}
+}
+
+// quotedNotes returns the notes a prompt quotes with quoteNotes, or the whole
+// prompt if it quotes none
+func quotedNotes(prompt string) string {
+	start := strings.Index(prompt, notesOpen+"\n")
+	end := strings.LastIndex(prompt, "\n"+notesClose)
+	if start < 0 || end < start+len(notesOpen)+1 {
+		return prompt
+	}
+	return prompt[start+len(notesOpen)+1 : end]
+}
+
+// promptInstructions returns the part of a prompt before the quoted notes
+func promptInstructions(prompt string) string {
+	instructions, _, _ := strings.Cut(prompt, notesOpen)
+	return instructions
+}
+
+// promptSummaryStyle finds the summary style whose format the prompt asks
+// for, or returns "" for prompts that don't ask for one
+func promptSummaryStyle(prompt string) string {
+	instructions := promptInstructions(prompt)
+	for style, format := range summaryFormats {
+		if strings.Contains(instructions, format) {
+			return style
+		}
+	}
+	return ""
+}
+
+// promptQuestionTypes lists the question types a quiz prompt asks for, in
+// the order of its "- type: rule" lines
+func promptQuestionTypes(prompt string) []string {
+	var types []string
+	for _, line := range strings.Split(promptInstructions(prompt), "\n") {
+		rest, ok := strings.CutPrefix(line, "- ")
+		if !ok {
+			continue
+		}
+		if name, _, _ := strings.Cut(rest, ":"); questionFormats[name].rule != "" {
+			types = append(types, name)
+		}
+	}
+	return types
+}
+
+// Stream emits the Complete output word by word
+func (p *MockProvider) Stream(ctx context.Context, prompt string, opts CompletionOptions, onToken TokenFunc) (string, error) {
+	text, err := p.Complete(ctx, prompt, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, token := range strings.SplitAfter(text, " ") {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onToken(token); err != nil {
+			return "", err
+		}
+	}
+
+	return text, nil
+}
+
+// Embed hashes the words of text into a normalized bag-of-words vector so
+// that texts sharing vocabulary end up close together
+func (p *MockProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vector := make([]float32, EmbeddingDimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%d:%s", p.Seed, word)
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vector[(sum>>1)%EmbeddingDimensions] += sign
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm == 0 {
+		// Keep empty input distinguishable from a zero vector
+		vector[0] = 1
+		return vector, nil
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+
+	return vector, nil
+}
+
+// rand returns a generator seeded by the provider seed, task and input
+func (p *MockProvider) rand(task, input string) *rand.Rand {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%s:%s", p.Seed, task, input)
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// marshalMock wraps items under key the same way structured output does
+func marshalMock(key string, items interface{}) (string, error) {
+	data, err := json.Marshal(map[string]interface{}{key: items})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
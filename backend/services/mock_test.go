@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMockEmbed(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockProvider(1)
+
+	embed := func(text string) []float32 {
+		t.Helper()
+		vector, err := provider.Embed(ctx, text)
+		if err != nil {
+			t.Fatalf("Embed(%q): %v", text, err)
+		}
+		if len(vector) != EmbeddingDimensions {
+			t.Fatalf("got %d dimensions, want %d", len(vector), EmbeddingDimensions)
+		}
+		return vector
+	}
+	similarity := func(a, b []float32) float64 {
+		var dot float64
+		for i := range a {
+			dot += float64(a[i] * b[i])
+		}
+		return dot
+	}
+
+	plants := embed("plants turn light into chemical energy")
+	if !reflect.DeepEqual(plants, embed("plants turn light into chemical energy")) {
+		t.Error("the same text gave different embeddings")
+	}
+	if norm := math.Sqrt(similarity(plants, plants)); math.Abs(norm-1) > 1e-5 {
+		t.Errorf("embedding norm is %f, want 1", norm)
+	}
+
+	related := embed("chemical energy from light in plants")
+	unrelated := embed("the treaty ended the war in europe")
+	if similarity(plants, related) <= similarity(plants, unrelated) {
+		t.Error("texts sharing words are not closer than unrelated texts")
+	}
+
+	other, err := NewMockProvider(2).Embed(ctx, "plants turn light into chemical energy")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if reflect.DeepEqual(plants, other) {
+		t.Error("different seeds gave the same embedding")
+	}
+
+	if empty := embed(""); empty[0] != 1 {
+		t.Error("empty text should embed to a unit vector")
+	}
+}
+
+func TestMockCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	provider := NewMockProvider(1)
+	if _, err := provider.Complete(ctx, "Summarize", CompletionOptions{Task: TaskSummary}); err == nil {
+		t.Error("Complete succeeded with a canceled context")
+	}
+	if _, err := provider.Embed(ctx, "text"); err == nil {
+		t.Error("Embed succeeded with a canceled context")
+	}
+}
+
+func TestMockReadsPrompt(t *testing.T) {
+	prompt := "Use these types:\n- cloze: a blank\n- true_false: a statement\n- unknown: ignored\n\n" +
+		quoteNotes("- essay: part of the notes\n"+testNote) + "\n\nReturn JSON"
+	if got := quotedNotes(prompt); got != "- essay: part of the notes\n"+testNote {
+		t.Errorf("quotedNotes = %q", got)
+	}
+	if got := quotedNotes("No notes here"); got != "No notes here" {
+		t.Errorf("quotedNotes without notes = %q", got)
+	}
+	if got := promptQuestionTypes(prompt); !reflect.DeepEqual(got, []string{QuestionCloze, QuestionTrueFalse}) {
+		t.Errorf("promptQuestionTypes = %v", got)
+	}
+
+	summaryPrompt := "Summarize as " + summaryFormats[SummaryOutline] + ":\n\n" + quoteNotes(summaryFormats[SummaryBullets])
+	if got := promptSummaryStyle(summaryPrompt); got != SummaryOutline {
+		t.Errorf("promptSummaryStyle = %q, want %q", got, SummaryOutline)
+	}
+	if got := promptSummaryStyle("Merge these"); got != "" {
+		t.Errorf("promptSummaryStyle without a style = %q", got)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type OllamaGenerateRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+type OllamaGenerateResponse struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+}
+
+type OllamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type OllamaEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type OllamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OllamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// OllamaProvider runs generation and embeddings against a self-hosted
+// Ollama server
+type OllamaProvider struct {
+	BaseURL    string
+	Model      string
+	EmbedModel string
+	Client     *http.Client
+}
+
+// NewOllamaProvider creates a provider for the Ollama server at baseURL
+func NewOllamaProvider(baseURL, model, embedModel string) *OllamaProvider {
+	return &OllamaProvider{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Model:      model,
+		EmbedModel: embedModel,
+		Client:     &http.Client{},
+	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+// Complete calls the non-streaming /api/generate endpoint
+func (p *OllamaProvider) Complete(ctx context.Context, prompt string, opts CompletionOptions) (string, error) {
+	reqBody := p.generateRequest(prompt, opts, false)
+
+	var genResp OllamaGenerateResponse
+	if err := p.post(ctx, "/api/generate", reqBody, &genResp); err != nil {
+		return "", err
+	}
+	if genResp.Error != "" {
+		return "", fmt.Errorf("Ollama error: %s", genResp.Error)
+	}
+
+	return genResp.Response, nil
+}
+
+// Stream calls /api/generate in streaming mode, which replies with one JSON
+// object per line
+func (p *OllamaProvider) Stream(ctx context.Context, prompt string, opts CompletionOptions, onToken TokenFunc) (string, error) {
+	jsonData, err := json.Marshal(p.generateRequest(prompt, opts, true))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/api/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("Ollama API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var genResp OllamaGenerateResponse
+		if err := json.Unmarshal(line, &genResp); err != nil {
+			return text.String(), fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if genResp.Error != "" {
+			return text.String(), fmt.Errorf("Ollama error: %s", genResp.Error)
+		}
+
+		text.WriteString(genResp.Response)
+		if err := onToken(genResp.Response); err != nil {
+			return text.String(), err
+		}
+		if genResp.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return text.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return text.String(), nil
+}
+
+// generateRequest builds an /api/generate request body
+func (p *OllamaProvider) generateRequest(prompt string, opts CompletionOptions, stream bool) OllamaGenerateRequest {
+	model := p.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	reqBody := OllamaGenerateRequest{
+		Model:   model,
+		Prompt:  prompt,
+		Stream:  stream,
+		Options: map[string]interface{}{},
+	}
+	if opts.MaxTokens > 0 {
+		reqBody.Options["num_predict"] = opts.MaxTokens
+	}
+	if opts.Temperature > 0 {
+		reqBody.Options["temperature"] = opts.Temperature
+	}
+	return reqBody
+}
+
+// Embed calls the /api/embeddings endpoint with the configured embedding model
+func (p *OllamaProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	reqBody := OllamaEmbeddingRequest{
+		Model:  p.EmbedModel,
+		Prompt: text,
+	}
+
+	var embResp OllamaEmbeddingResponse
+	if err := p.post(ctx, "/api/embeddings", reqBody, &embResp); err != nil {
+		return nil, err
+	}
+	if embResp.Error != "" {
+		return nil, fmt.Errorf("Ollama error: %s", embResp.Error)
+	}
+	if len(embResp.Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding returned from Ollama")
+	}
+
+	return embResp.Embedding, nil
+}
+
+// EmbedBatch embeds all texts with one call to the /api/embed endpoint
+func (p *OllamaProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody := OllamaEmbedRequest{
+		Model: p.EmbedModel,
+		Input: texts,
+	}
+
+	var embResp OllamaEmbedResponse
+	if err := p.post(ctx, "/api/embed", reqBody, &embResp); err != nil {
+		return nil, err
+	}
+	if embResp.Error != "" {
+		return nil, fmt.Errorf("Ollama error: %s", embResp.Error)
+	}
+	if len(embResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings from Ollama for %d texts", len(embResp.Embeddings), len(texts))
+	}
+
+	return embResp.Embeddings, nil
+}
+
+// post sends a JSON request to the Ollama server and decodes the JSON reply
+func (p *OllamaProvider) post(ctx context.Context, path string, reqBody, out interface{}) error {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Ollama API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newOllamaServer stands in for an Ollama server, answering /api/generate
+// with reply and /api/embeddings and /api/embed with fixed vectors
+func newOllamaServer(t *testing.T, reply string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/generate":
+			var req OllamaGenerateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !req.Stream {
+				json.NewEncoder(w).Encode(OllamaGenerateResponse{Response: reply, Done: true})
+				return
+			}
+			words := strings.SplitAfter(reply, " ")
+			for i, word := range words {
+				json.NewEncoder(w).Encode(OllamaGenerateResponse{Response: word, Done: i == len(words)-1})
+			}
+		case "/api/embeddings":
+			json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: []float32{0.1, 0.2, 0.3}})
+		case "/api/embed":
+			var req OllamaEmbedRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var resp OllamaEmbedResponse
+			for range req.Input {
+				resp.Embeddings = append(resp.Embeddings, []float32{0.1, 0.2, 0.3})
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaComplete(t *testing.T) {
+	server := newOllamaServer(t, "A summary from the model.")
+	provider := NewOllamaProvider(server.URL+"/", "llama3", "nomic-embed-text")
+
+	text, err := provider.Complete(context.Background(), "Summarize", CompletionOptions{MaxTokens: 50})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if text != "A summary from the model." {
+		t.Errorf("Complete = %q", text)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	server := newOllamaServer(t, "one two three")
+	provider := NewOllamaProvider(server.URL, "llama3", "")
+
+	var tokens []string
+	text, err := provider.Stream(context.Background(), "Count", CompletionOptions{}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if text != "one two three" || len(tokens) != 3 {
+		t.Errorf("Stream = %q in %d tokens", text, len(tokens))
+	}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	server := newOllamaServer(t, "")
+	provider := NewOllamaProvider(server.URL, "llama3", "nomic-embed-text")
+
+	embedding, err := provider.Embed(context.Background(), "chloroplasts")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(embedding) != 3 {
+		t.Errorf("got %d dimensions, want 3", len(embedding))
+	}
+
+	embeddings, err := provider.EmbedBatch(context.Background(), []string{"chloroplasts", "stomata"})
+	if err != nil {
+		t.Fatalf("EmbedBatch: %v", err)
+	}
+	if len(embeddings) != 2 {
+		t.Errorf("got %d embeddings, want 2", len(embeddings))
+	}
+}
+
+func TestOllamaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(OllamaGenerateResponse{Error: "model not found"})
+	}))
+	defer server.Close()
+	provider := NewOllamaProvider(server.URL, "missing", "")
+
+	if _, err := provider.Complete(context.Background(), "Hello", CompletionOptions{}); err == nil {
+		t.Error("expected an error for a missing model")
+	}
+}
+
+func TestGenerateFlashcardsWithOllama(t *testing.T) {
+	cards := `[{"question": "Where does photosynthesis take place?", "answer": "In the chloroplasts", "explanation": "", "source": "It takes place in the chloroplasts of plant cells."}]`
+	server := newOllamaServer(t, fmt.Sprintf("Here are your flashcards:\n```json\n%s\n```", cards))
+	useProvider(t, NewOllamaProvider(server.URL, "llama3", ""))
+
+	flashcards, err := GenerateFlashcards(context.Background(), testNote, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("GenerateFlashcards: %v", err)
+	}
+	if len(flashcards) != 1 || flashcards[0].Answer != "In the chloroplasts" {
+		t.Fatalf("got %+v", flashcards)
+	}
+	if flashcards[0].Span == nil {
+		t.Error("the source sentence was not located in the note")
+	}
+}
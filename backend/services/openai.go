@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatCompletionRequest struct {
+	Model          string                 `json:"model"`
+	Messages       []ChatMessage          `json:"messages"`
+	MaxTokens      int                    `json:"max_tokens,omitempty"`
+	Temperature    float64                `json:"temperature,omitempty"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+	Stream         bool                   `json:"stream,omitempty"`
+}
+
+type ChatCompletionResponse struct {
+	Choices []struct {
+		Message ChatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+type ChatCompletionChunk struct {
+	Choices []struct {
+		Delta ChatMessage `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"` // Sent by some servers when generation fails mid-stream
+}
+
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// OpenAIProvider speaks the OpenAI-compatible /v1/chat/completions protocol
+// served by vLLM, llama.cpp server, LM Studio and friends
+type OpenAIProvider struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	EmbedModel string
+	Client     *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the server at baseURL, which
+// should include the /v1 prefix
+func NewOpenAIProvider(baseURL, apiKey, model, embedModel string) *OpenAIProvider {
+	return &OpenAIProvider{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		Model:      model,
+		EmbedModel: embedModel,
+		Client:     &http.Client{},
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// SupportsStructuredOutput reports that JSON schemas are enforced server-side
+func (p *OpenAIProvider) SupportsStructuredOutput() bool {
+	return true
+}
+
+// Complete sends the prompt as a single user message
+func (p *OpenAIProvider) Complete(ctx context.Context, prompt string, opts CompletionOptions) (string, error) {
+	reqBody := p.chatRequest(prompt, opts, false)
+
+	var chatResp ChatCompletionResponse
+	if err := p.post(ctx, "/chat/completions", reqBody, &chatResp); err != nil {
+		return "", err
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned from chat completion")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// Stream requests a streamed completion, delivered as server-sent events
+// each carrying a delta of the message
+func (p *OpenAIProvider) Stream(ctx context.Context, prompt string, opts CompletionOptions, onToken TokenFunc) (string, error) {
+	jsonData, err := json.Marshal(p.chatRequest(prompt, opts, true))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("OpenAI-compatible API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return text.String(), fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return text.String(), fmt.Errorf("OpenAI-compatible API error: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		token := chunk.Choices[0].Delta.Content
+		text.WriteString(token)
+		if err := onToken(token); err != nil {
+			return text.String(), err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return text.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return text.String(), nil
+}
+
+// chatRequest builds a chat completion request body
+func (p *OpenAIProvider) chatRequest(prompt string, opts CompletionOptions, stream bool) ChatCompletionRequest {
+	model := p.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	reqBody := ChatCompletionRequest{
+		Model:       model,
+		Messages:    []ChatMessage{{Role: "user", Content: prompt}},
+		MaxTokens:   opts.MaxTokens,
+		Temperature: opts.Temperature,
+		Stream:      stream,
+	}
+	if opts.JSONSchema != nil {
+		reqBody.ResponseFormat = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   opts.Task,
+				"schema": opts.JSONSchema,
+				"strict": true,
+			},
+		}
+	}
+	return reqBody
+}
+
+// Embed calls the /embeddings endpoint when an embedding model is configured
+func (p *OpenAIProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := p.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch embeds all texts with one /embeddings request
+func (p *OpenAIProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.EmbedModel == "" {
+		return nil, ErrNoEmbeddingModel
+	}
+
+	reqBody := OpenAIEmbeddingRequest{
+		Model: p.EmbedModel,
+		Input: texts,
+	}
+
+	var embResp OpenAIEmbeddingResponse
+	if err := p.post(ctx, "/embeddings", reqBody, &embResp); err != nil {
+		return nil, err
+	}
+	if len(embResp.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(embResp.Data), len(texts))
+	}
+
+	// Servers may return the embeddings in any order
+	embeddings := make([][]float32, len(texts))
+	for _, data := range embResp.Data {
+		if data.Index < 0 || data.Index >= len(texts) || len(data.Embedding) == 0 {
+			return nil, fmt.Errorf("no embedding returned")
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	for _, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("no embedding returned")
+		}
+	}
+
+	return embeddings, nil
+}
+
+// post sends a JSON request to the server and decodes the JSON reply
+func (p *OpenAIProvider) post(ctx context.Context, path string, reqBody, out interface{}) error {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpenAI-compatible API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// openAIServer stands in for an OpenAI-compatible server. It answers
+// /chat/completions with reply, streamed as server-sent events when asked,
+// and /embeddings with vectors of the given dimensions, and keeps the last
+// request it was sent.
+type openAIServer struct {
+	*httptest.Server
+	reply      string
+	dimensions int
+	request    ChatCompletionRequest
+	auth       string
+	embeds     []OpenAIEmbeddingRequest
+}
+
+func newOpenAIServer(t *testing.T, reply string) *openAIServer {
+	t.Helper()
+	s := &openAIServer{reply: reply, dimensions: 3}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			if err := json.NewDecoder(r.Body).Decode(&s.request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !s.request.Stream {
+				fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}}]}`, s.reply)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": keep-alive\n\n")
+			for _, word := range strings.SplitAfter(s.reply, " ") {
+				fmt.Fprintf(w, "data: {\"choices\": [{\"delta\": {\"content\": %q}}]}\n\n", word)
+			}
+			// Anything after the sentinel is ignored
+			fmt.Fprint(w, "data: [DONE]\n\ndata: {\"choices\": [{\"delta\": {\"content\": \"extra\"}}]}\n\n")
+		case "/v1/embeddings":
+			var req OpenAIEmbeddingRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.embeds = append(s.embeds, req)
+			var resp OpenAIEmbeddingResponse
+			resp.Data = make([]struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}, len(req.Input))
+			// Answer in reverse to check the embeddings are put back in order
+			for i := range req.Input {
+				resp.Data[i].Index = len(req.Input) - 1 - i
+				resp.Data[i].Embedding = make([]float32, s.dimensions)
+				resp.Data[i].Embedding[0] = float32(len(req.Input) - 1 - i)
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestOpenAIComplete(t *testing.T) {
+	server := newOpenAIServer(t, "A summary from the model.")
+	provider := NewOpenAIProvider(server.URL+"/v1/", "secret", "qwen", "")
+
+	text, err := provider.Complete(context.Background(), "Summarize", CompletionOptions{MaxTokens: 50, Temperature: 0.2})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if text != "A summary from the model." {
+		t.Errorf("Complete = %q", text)
+	}
+
+	req := server.request
+	if req.Model != "qwen" || req.MaxTokens != 50 || req.Temperature != 0.2 || req.Stream {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content != "Summarize" {
+		t.Errorf("unexpected messages %+v", req.Messages)
+	}
+	if req.ResponseFormat != nil {
+		t.Errorf("response format sent without a schema: %v", req.ResponseFormat)
+	}
+	if server.auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", server.auth)
+	}
+}
+
+func TestOpenAIJSONSchema(t *testing.T) {
+	server := newOpenAIServer(t, `{"score": 80, "feedback": "Good.", "missing_points": []}`)
+	provider := NewOpenAIProvider(server.URL+"/v1", "", "qwen", "")
+
+	opts := CompletionOptions{Task: TaskGrading, Model: "qwen-large", JSONSchema: gradeSchema}
+	if _, err := provider.Complete(context.Background(), "Grade", opts); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	req := server.request
+	if req.Model != "qwen-large" {
+		t.Errorf("model = %q, want the override", req.Model)
+	}
+	if req.ResponseFormat["type"] != "json_schema" {
+		t.Fatalf("response format = %v", req.ResponseFormat)
+	}
+	schema, _ := req.ResponseFormat["json_schema"].(map[string]interface{})
+	if schema["name"] != TaskGrading || schema["strict"] != true {
+		t.Errorf("json_schema = %v", schema)
+	}
+	if inner, _ := schema["schema"].(map[string]interface{}); inner["type"] != "object" || inner["additionalProperties"] != false {
+		t.Errorf("schema = %v", schema["schema"])
+	}
+	if server.auth != "" {
+		t.Errorf("Authorization sent without a key: %q", server.auth)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	server := newOpenAIServer(t, "one two three")
+	provider := NewOpenAIProvider(server.URL+"/v1", "", "qwen", "")
+
+	var tokens []string
+	text, err := provider.Stream(context.Background(), "Count", CompletionOptions{}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if text != "one two three" || len(tokens) != 3 {
+		t.Errorf("Stream = %q in %d tokens", text, len(tokens))
+	}
+	if !server.request.Stream {
+		t.Error("request did not ask for a stream")
+	}
+}
+
+func TestOpenAIStreamStopsOnCallbackError(t *testing.T) {
+	server := newOpenAIServer(t, "one two three")
+	provider := NewOpenAIProvider(server.URL+"/v1", "", "qwen", "")
+
+	stop := fmt.Errorf("client went away")
+	text, err := provider.Stream(context.Background(), "Count", CompletionOptions{}, func(token string) error {
+		return stop
+	})
+	if err != stop || text != "one " {
+		t.Errorf("Stream = %q, %v; want the first token and the callback's error", text, err)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"error status", http.StatusBadRequest, `{"error": {"message": "model qwen does not exist"}}`, "model qwen does not exist"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "no choices"},
+		{"invalid JSON", http.StatusOK, `not json`, "unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			provider := NewOpenAIProvider(server.URL, "", "qwen", "")
+
+			_, err := provider.Complete(context.Background(), "Hello", CompletionOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		text   string
+		want   string
+	}{
+		{"error status", http.StatusTooManyRequests, `{"error": {"message": "rate limited"}}`, "", "rate limited"},
+		{"error event", http.StatusOK, "data: {\"choices\": [{\"delta\": {\"content\": \"Half\"}}]}\n\ndata: {\"error\": {\"message\": \"out of memory\"}}\n\n", "Half", "out of memory"},
+		{"invalid event", http.StatusOK, "data: {oops\n\n", "", "unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			provider := NewOpenAIProvider(server.URL, "", "qwen", "")
+
+			text, err := provider.Stream(context.Background(), "Hello", CompletionOptions{}, func(string) error { return nil })
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.want)
+			}
+			if text != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	server := newOpenAIServer(t, "")
+
+	if _, err := NewOpenAIProvider(server.URL+"/v1", "", "qwen", "").Embed(context.Background(), "leaf"); err != ErrNoEmbeddingModel {
+		t.Errorf("Embed without a model: %v, want ErrNoEmbeddingModel", err)
+	}
+
+	embedding, err := NewOpenAIProvider(server.URL+"/v1", "", "qwen", "bge-small").Embed(context.Background(), "leaf")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(embedding) != 3 {
+		t.Errorf("got %d dimensions, want 3", len(embedding))
+	}
+
+	embeddings, err := NewOpenAIProvider(server.URL+"/v1", "", "qwen", "bge-small").EmbedBatch(context.Background(), []string{"leaf", "root", "stem"})
+	if err != nil {
+		t.Fatalf("EmbedBatch: %v", err)
+	}
+	for i, embedding := range embeddings {
+		if embedding[0] != float32(i) {
+			t.Errorf("embedding %d is out of order: %v", i, embedding)
+		}
+	}
+	if n := len(server.embeds); n != 2 {
+		t.Errorf("sent %d embedding requests, want one per call", n)
+	}
+}
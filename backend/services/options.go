@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxGenerateCount caps how many flashcards or questions one request can ask
+// for, to keep the response within the model's output limit
+const MaxGenerateCount = 30
+
+// maxLanguageLength is plenty for a language name
+const maxLanguageLength = 50
+
+// GenerationOptions tune generated flashcards and quizzes. The zero value
+// leaves everything to the model.
+type GenerationOptions struct {
+	Difficulty string `json:"difficulty,omitempty"` // "easy", "medium" or "hard"
+	Level      string `json:"level,omitempty"`      // Cognitive level: "recall", "apply" or "analyze"
+	Count      int    `json:"count,omitempty"`      // How many to generate, up to MaxGenerateCount
+	Language   string `json:"language,omitempty"`   // Language to write in, such as "French"
+}
+
+// difficultyInstructions and levelInstructions are added to the prompt for
+// each option
+var difficultyInstructions = map[string]string{
+	"easy":   "Keep them easy: straightforward facts and definitions for a first pass over the material.",
+	"medium": "Pitch them at medium difficulty for a student who has read the material once.",
+	"hard":   "Make them hard: precise details, subtle distinctions and answers that take real understanding.",
+}
+
+var levelInstructions = map[string]string{
+	"recall":  "Test recall: ask for facts, terms and definitions as the text states them.",
+	"apply":   "Test application: ask the student to use the ideas on new examples, cases or problems.",
+	"analyze": "Test analysis: ask the student to compare ideas, break them down and explain how they relate.",
+}
+
+// Validate checks the options against the supported values
+func (o GenerationOptions) Validate() error {
+	if _, ok := difficultyInstructions[o.Difficulty]; o.Difficulty != "" && !ok {
+		return fmt.Errorf("difficulty must be easy, medium or hard")
+	}
+	if _, ok := levelInstructions[o.Level]; o.Level != "" && !ok {
+		return fmt.Errorf("level must be recall, apply or analyze")
+	}
+	if o.Count < 0 || o.Count > MaxGenerateCount {
+		return fmt.Errorf("count must be between 0 (the default) and %d", MaxGenerateCount)
+	}
+	if len(o.Language) > maxLanguageLength {
+		return fmt.Errorf("language must be at most %d characters", maxLanguageLength)
+	}
+	return nil
+}
+
+// count describes how many items to ask the model for
+func (o GenerationOptions) count(defaultRange string) string {
+	if o.Count > 0 {
+		return fmt.Sprintf("exactly %d", o.Count)
+	}
+	return defaultRange
+}
+
+// instructions are the extra prompt lines for the options, starting with a
+// blank line, or "" when there are none
+func (o GenerationOptions) instructions() string {
+	var lines []string
+	if o.Difficulty != "" {
+		lines = append(lines, difficultyInstructions[o.Difficulty])
+	}
+	if o.Level != "" {
+		lines = append(lines, levelInstructions[o.Level])
+	}
+	if language := strings.TrimSpace(o.Language); language != "" {
+		lines = append(lines, fmt.Sprintf("Write everything in %s, whatever language the text is in, except the source sentences, which stay exactly as they appear in the text.", language))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n\n" + strings.Join(lines, "\n")
+}
+
+// Summary styles
+const (
+	SummaryStandard   = "standard"
+	SummaryBullets    = "bullets"
+	SummaryOutline    = "outline"
+	SummaryTLDR       = "tldr"
+	SummaryCheatSheet = "cheat_sheet"
+)
+
+// summaryFormats describe the shape of each summary style to the model
+var summaryFormats = map[string]string{
+	SummaryStandard:   "a comprehensive, well-structured summary that explains the main points and key concepts",
+	SummaryBullets:    `a bulleted list of the key points, one idea per bullet, each line starting with "- "`,
+	SummaryOutline:    "a hierarchical outline: numbered main topics in the order they appear, with indented sub-points under each",
+	SummaryTLDR:       "a TL;DR: the single most important takeaway in plain sentences, without headings or lists",
+	SummaryCheatSheet: "an exam cheat-sheet: short sections for key terms with one-line definitions, formulas and rules, important facts, and the points most likely to be examined, as terse as possible",
+}
+
+// summaryLengths are the word budgets of each summary length. A TL;DR gets
+// a quarter of the budget.
+var summaryLengths = map[string]int{
+	"short":  100,
+	"medium": 250,
+	"long":   500,
+}
+
+// SummaryOptions pick the style and length of a summary. Empty fields take
+// the standard style and medium length.
+type SummaryOptions struct {
+	Style  string `json:"style,omitempty"`  // "standard", "bullets", "outline", "tldr" or "cheat_sheet"
+	Length string `json:"length,omitempty"` // "short", "medium" or "long"
+}
+
+// Validate checks the options against the supported values
+func (o SummaryOptions) Validate() error {
+	if _, ok := summaryFormats[o.Style]; o.Style != "" && !ok {
+		return fmt.Errorf("style must be standard, bullets, outline, tldr or cheat_sheet")
+	}
+	if _, ok := summaryLengths[o.Length]; o.Length != "" && !ok {
+		return fmt.Errorf("length must be short, medium or long")
+	}
+	return nil
+}
+
+// WithDefaults fills in the default style and length
+func (o SummaryOptions) WithDefaults() SummaryOptions {
+	if o.Style == "" {
+		o.Style = SummaryStandard
+	}
+	if o.Length == "" {
+		o.Length = "medium"
+	}
+	return o
+}
+
+// words is the word budget for the summary
+func (o SummaryOptions) words() int {
+	o = o.WithDefaults()
+	words := summaryLengths[o.Length]
+	if o.Style == SummaryTLDR {
+		words /= 4
+	}
+	return words
+}
+
+// format describes the summary to write, for use in a prompt
+func (o SummaryOptions) format() string {
+	return summaryFormats[o.WithDefaults().Style]
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OutlineNode is an item of an outline with the items nested under it
+type OutlineNode struct {
+	Text     string         `json:"text"`
+	Children []*OutlineNode `json:"children,omitempty"`
+}
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	numberedPattern = regexp.MustCompile(`^(?:((?:\d+|[A-Za-z]|[IVXivx]+)(?:\.\d+)*)[.)]|((?:\d+|[A-Za-z]|[IVXivx]+)(?:\.\d+)+))\s+(.*)$`)
+	bulletPattern   = regexp.MustCompile(`^[-*+•]\s+(.*)$`)
+)
+
+// ParseOutline turns a summary into an outline under a root titled title.
+// Markdown headings, numbered items such as "2.1" and bullets nest by their
+// level and indentation, a line ending in a colon heads the lines after it,
+// and plain text becomes one item per sentence, so every summary style
+// gives a usable structure.
+func ParseOutline(title, summary string) *OutlineNode {
+	root := &OutlineNode{Text: title}
+
+	type level struct {
+		depth int
+		node  *OutlineNode
+	}
+	var stack []level
+	add := func(depth int, text string) {
+		text = strings.TrimSpace(strings.ReplaceAll(text, "**", ""))
+		if text == "" {
+			return
+		}
+		for len(stack) > 0 && stack[len(stack)-1].depth >= depth {
+			stack = stack[:len(stack)-1]
+		}
+		parent := root
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1].node
+		}
+		node := &OutlineNode{Text: text}
+		parent.Children = append(parent.Children, node)
+		stack = append(stack, level{depth, node})
+	}
+
+	// Headings and sections set the depth of the items below them, and
+	// indentation and numbering nest items further. Depths only need to
+	// compare correctly.
+	lines := strings.Split(summary, "\n")
+	lettered := letteredItems(lines)
+	sectionBase, base := 0, 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indent := indentWidth(line) / 2
+
+		if match := headingPattern.FindStringSubmatch(trimmed); match != nil {
+			depth := (len(match[1]) - 1) * 100
+			add(depth, match[2])
+			sectionBase, base = depth+100, depth+100
+			continue
+		}
+		if match := numberedPattern.FindStringSubmatch(trimmed); match != nil {
+			if marker := match[1] + match[2]; !isLetterMarker(marker) || lettered[i] {
+				add(base+10*(strings.Count(marker, ".")+indent), match[3])
+				continue
+			}
+		}
+		if match := bulletPattern.FindStringSubmatch(trimmed); match != nil {
+			add(base+10*indent+5, match[1])
+			continue
+		}
+		if strings.HasSuffix(trimmed, ":") && len(trimmed) <= 80 {
+			depth := sectionBase + 10*indent
+			add(depth, strings.TrimSuffix(trimmed, ":"))
+			base = depth + 10
+			continue
+		}
+		for _, sentence := range splitSentences(trimmed) {
+			add(base+10*indent, sentence)
+		}
+	}
+	return root
+}
+
+// isLetterMarker reports whether an item is numbered with a lone letter or
+// roman numeral rather than digits
+func isLetterMarker(marker string) bool {
+	return !strings.ContainsAny(marker, ".0123456789")
+}
+
+// letteredItems finds the lines numbered with letters or roman numerals
+// that are list items: those followed by a line with the next letter or
+// numeral, the lines that follow them, and lists of one once the text has
+// used lettered lists in the same case. Otherwise a lone "A." is more
+// likely the initial in "A. Smith argued".
+func letteredItems(lines []string) map[int]bool {
+	var indexes []int
+	var markers []string
+	for i, line := range lines {
+		match := numberedPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match != nil && isLetterMarker(match[1]+match[2]) {
+			indexes = append(indexes, i)
+			markers = append(markers, match[1]+match[2])
+		}
+	}
+
+	items := map[int]bool{}
+	for k := range markers {
+		for j := k + 1; j < len(markers); j++ {
+			if followsMarker(markers[k], markers[j]) {
+				items[indexes[k]], items[indexes[j]] = true, true
+				break
+			}
+		}
+	}
+
+	listed := map[bool]bool{} // Whether a list was seen, by upper case
+	for k, marker := range markers {
+		upper := marker == strings.ToUpper(marker)
+		if items[indexes[k]] {
+			listed[upper] = true
+		} else if listed[upper] && (strings.EqualFold(marker, "a") || strings.EqualFold(marker, "i")) {
+			items[indexes[k]] = true
+		}
+	}
+	return items
+}
+
+// followsMarker reports whether next is the letter or roman numeral after
+// marker, in the same case
+func followsMarker(marker, next string) bool {
+	upper := marker == strings.ToUpper(marker)
+	if upper != (next == strings.ToUpper(next)) {
+		return false
+	}
+	if len(marker) == 1 && len(next) == 1 && next[0] == marker[0]+1 {
+		return true
+	}
+	value := romanValue(marker)
+	return value > 0 && romanValue(next) == value+1
+}
+
+// romanValue reads a roman numeral made of I, V and X, or returns 0 if
+// marker isn't one
+func romanValue(marker string) int {
+	values := map[byte]int{'i': 1, 'v': 5, 'x': 10}
+	lower := strings.ToLower(marker)
+	total := 0
+	for i := 0; i < len(lower); i++ {
+		value, ok := values[lower[i]]
+		if !ok {
+			return 0
+		}
+		if i+1 < len(lower) && values[lower[i+1]] > value {
+			total -= value
+		} else {
+			total += value
+		}
+	}
+	return total
+}
+
+// indentWidth counts the leading whitespace of a line, a tab as four spaces
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// splitSentences splits text after each full stop, question mark or
+// exclamation mark that ends a sentence
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if (text[i] == '.' || text[i] == '!' || text[i] == '?') && (i+1 == len(text) || text[i+1] == ' ') && !isInitial(text, i) {
+			sentences = append(sentences, strings.TrimSpace(text[start:i+1]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// isInitial reports whether the full stop at i follows a lone capital, as
+// in "A. Smith", rather than ending a sentence
+func isInitial(text string, i int) bool {
+	return text[i] == '.' && i+1 < len(text) && i >= 1 && text[i-1] >= 'A' && text[i-1] <= 'Z' && (i == 1 || text[i-2] == ' ')
+}
+
+// opmlOutline is an outline element of an OPML document
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Children []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+// OPML writes an outline as an OPML 2.0 document, which mind-mapping and
+// outliner tools import
+func OPML(root *OutlineNode) (string, error) {
+	var convert func(node *OutlineNode) opmlOutline
+	convert = func(node *OutlineNode) opmlOutline {
+		outline := opmlOutline{Text: node.Text}
+		for _, child := range node.Children {
+			outline.Children = append(outline.Children, convert(child))
+		}
+		return outline
+	}
+
+	data, err := xml.MarshalIndent(opmlDocument{
+		Version: "2.0",
+		Title:   root.Text,
+		Body:    []opmlOutline{convert(root)},
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to write OPML: %w", err)
+	}
+	return xml.Header + string(data) + "\n", nil
+}
+
+// MarkdownOutline writes an outline as a Markdown heading followed by a
+// nested bullet list
+func MarkdownOutline(root *OutlineNode) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", root.Text)
+
+	var write func(nodes []*OutlineNode, depth int)
+	write = func(nodes []*OutlineNode, depth int) {
+		for _, node := range nodes {
+			fmt.Fprintf(&b, "%s- %s\n", strings.Repeat("  ", depth), node.Text)
+			write(node.Children, depth+1)
+		}
+	}
+	write(root.Children, 0)
+	return b.String()
+}
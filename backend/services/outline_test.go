@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// outlineTree writes an outline's items one per line, indented by depth
+func outlineTree(root *OutlineNode) string {
+	var b strings.Builder
+	var write func(nodes []*OutlineNode, depth int)
+	write = func(nodes []*OutlineNode, depth int) {
+		for _, node := range nodes {
+			fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", depth), node.Text)
+			write(node.Children, depth+1)
+		}
+	}
+	write(root.Children, 0)
+	return b.String()
+}
+
+func TestParseOutline(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		want    string
+	}{
+		{
+			name:    "headings",
+			summary: "# Photosynthesis\n## Light reactions\nSplit water.\n## Calvin cycle\n### Inputs\nCarbon dioxide.",
+			want:    "Photosynthesis\n  Light reactions\n    Split water.\n  Calvin cycle\n    Inputs\n      Carbon dioxide.\n",
+		},
+		{
+			name:    "numbered",
+			summary: "1. Light reactions\n1.1 Split water\n1.2 Make ATP\n2. Calvin cycle\n  a) Fix carbon\n  b) Make glucose",
+			want:    "Light reactions\n  Split water\n  Make ATP\nCalvin cycle\n  Fix carbon\n  Make glucose\n",
+		},
+		{
+			name:    "lettered and roman",
+			summary: "I. Inputs\nA. Light\nB. Water\nII. Outputs\nA. Glucose",
+			want:    "Inputs\nLight\nWater\nOutputs\nGlucose\n",
+		},
+		{
+			name:    "initials are not numbering",
+			summary: "A. Smith argued that plants need light. C. Jones disagreed.",
+			want:    "A. Smith argued that plants need light.\nC. Jones disagreed.\n",
+		},
+		{
+			name:    "bullets",
+			summary: "- Light reactions\n  - Split water\n  * Make ATP\n- **Calvin cycle**",
+			want:    "Light reactions\n  Split water\n  Make ATP\nCalvin cycle\n",
+		},
+		{
+			name:    "colon sections",
+			summary: "Key terms:\n- Chlorophyll: a pigment\n- Stomata: pores\nFormulas:\n6CO2 + 6H2O -> C6H12O6 + 6O2",
+			want:    "Key terms\n  Chlorophyll: a pigment\n  Stomata: pores\nFormulas\n  6CO2 + 6H2O -> C6H12O6 + 6O2\n",
+		},
+		{
+			name:    "plain prose",
+			summary: "Plants make glucose. They need light! Why green? Chlorophyll.",
+			want:    "Plants make glucose.\nThey need light!\nWhy green?\nChlorophyll.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := ParseOutline("Biology", tt.summary)
+			if root.Text != "Biology" {
+				t.Errorf("root = %q, want the title", root.Text)
+			}
+			if got := outlineTree(root); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutlineExports(t *testing.T) {
+	root := ParseOutline(`Cells & "energy"`, "1. Light <reactions>\n  - Split water\n2. Calvin cycle")
+
+	opml, err := OPML(root)
+	if err != nil {
+		t.Fatalf("OPML: %v", err)
+	}
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<opml version="2.0">`,
+		`<title>Cells &amp; &#34;energy&#34;</title>`,
+		`<outline text="Light &lt;reactions&gt;">`,
+		`<outline text="Split water"></outline>`,
+		`<outline text="Calvin cycle"></outline>`,
+	} {
+		if !strings.Contains(opml, want) {
+			t.Errorf("OPML is missing %s:\n%s", want, opml)
+		}
+	}
+
+	want := "# Cells & \"energy\"\n\n- Light <reactions>\n  - Split water\n- Calvin cycle\n"
+	if got := MarkdownOutline(root); got != want {
+		t.Errorf("MarkdownOutline =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"studypartner/config"
+)
+
+// Generation tasks, used by providers to pick a suitable model
+const (
+	TaskSummary    = "summary"
+	TaskFlashcards = "flashcards"
+	TaskQuiz       = "quiz"
+	TaskAnswer     = "answer"
+	TaskTutor      = "tutor"
+	TaskGrading    = "grading"
+	TaskGlossary   = "glossary"
+	TaskConcepts   = "concepts"
+)
+
+// CompletionOptions tunes a single completion request
+type CompletionOptions struct {
+	Task        string  // One of the Task* constants
+	Model       string  // Overrides the provider's default model when set
+	MaxTokens   int     // Upper bound on generated tokens, 0 for provider default
+	Temperature float64 // Sampling temperature, 0 for provider default
+
+	// JSONSchema constrains the response to a JSON document matching the
+	// schema on providers that support structured output
+	JSONSchema map[string]interface{}
+}
+
+// Prompts quote the note material they work on between these tags, so the
+// model can tell it apart from the instructions
+const (
+	notesOpen  = "<notes>"
+	notesClose = "</notes>"
+)
+
+// quoteNotes wraps text taken from the user's notes for a prompt
+func quoteNotes(text string) string {
+	return notesOpen + "\n" + text + "\n" + notesClose
+}
+
+// Provider is an LLM backend capable of completing a text prompt
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, prompt string, opts CompletionOptions) (string, error)
+}
+
+// Embedder is implemented by providers that can also produce embeddings
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// BatchEmbedder is implemented by embedders that can embed several texts in
+// one request
+type BatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// StructuredOutputProvider is implemented by providers that enforce
+// CompletionOptions.JSONSchema server-side
+type StructuredOutputProvider interface {
+	SupportsStructuredOutput() bool
+}
+
+// ErrNoEmbeddingModel is returned by Embed when the provider has no
+// embedding model configured
+var ErrNoEmbeddingModel = errors.New("no embedding model configured")
+
+var (
+	providerMu     sync.RWMutex
+	activeProvider Provider
+)
+
+// NewProvider builds the provider selected by cfg.AIProvider
+func NewProvider(cfg *config.Config) (Provider, error) {
+	switch strings.ToLower(cfg.AIProvider) {
+	case "", "huggingface":
+		return NewHuggingFaceProvider(cfg.HuggingFaceKey), nil
+	case "ollama":
+		return NewOllamaProvider(cfg.OllamaURL, cfg.OllamaModel, cfg.OllamaEmbedModel), nil
+	case "openai":
+		return NewOpenAIProvider(cfg.OpenAIBaseURL, cfg.OpenAIKey, cfg.OpenAIModel, cfg.OpenAIEmbedModel), nil
+	case "mock":
+		return NewMockProvider(cfg.MockSeed), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.AIProvider)
+	}
+}
+
+// SetProvider replaces the provider used by the generation functions
+func SetProvider(p Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	activeProvider = p
+}
+
+// CurrentProvider returns the configured provider, defaulting to HuggingFace
+func CurrentProvider() Provider {
+	providerMu.RLock()
+	p := activeProvider
+	providerMu.RUnlock()
+	if p != nil {
+		return p
+	}
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if activeProvider == nil {
+		activeProvider = NewHuggingFaceProvider(os.Getenv("HUGGINGFACE_API_KEY"))
+	}
+	return activeProvider
+}
+
+// supportsStructuredOutput reports whether p enforces JSON schemas
+func supportsStructuredOutput(p Provider) bool {
+	sp, ok := p.(StructuredOutputProvider)
+	return ok && sp.SupportsStructuredOutput()
+}
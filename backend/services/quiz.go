@@ -0,0 +1,420 @@
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Quiz question types
+const (
+	QuestionMultipleChoice = "multiple_choice"
+	QuestionTrueFalse      = "true_false"
+	QuestionMultiSelect    = "multi_select"
+	QuestionCloze          = "cloze"
+	QuestionShortAnswer    = "short_answer"
+	QuestionEssay          = "essay"
+)
+
+// QuestionTypes lists every question type
+var QuestionTypes = []string{QuestionMultipleChoice, QuestionTrueFalse, QuestionMultiSelect, QuestionCloze, QuestionShortAnswer, QuestionEssay}
+
+// IsQuestionType reports whether t is a known question type
+func IsQuestionType(t string) bool {
+	for _, known := range QuestionTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWritten reports whether questions of type t are answered in the
+// student's own words and marked against a rubric
+func IsWritten(t string) bool {
+	return t == QuestionShortAnswer || t == QuestionEssay
+}
+
+// HasOptions reports whether questions of type t are answered by choosing
+// options rather than writing text
+func HasOptions(t string) bool {
+	return t == QuestionMultipleChoice || t == QuestionTrueFalse || t == QuestionMultiSelect
+}
+
+// trueFalseOptions are the options of every true/false question, so answer
+// 0 is true and 1 is false
+var trueFalseOptions = []string{"True", "False"}
+
+// blankPattern matches the gap in a cloze question
+var blankPattern = regexp.MustCompile(`_{3,}`)
+
+// ValidateQuestion checks that a question is complete for its type. Choice
+// questions need at least two distinct options and answers pointing at
+// them; duplicate options are rejected because a copy of the correct option
+// would be a second right answer. Cloze questions need a blank, and written
+// answers need a reference answer to grade against.
+func ValidateQuestion(q QuizData) error {
+	if strings.TrimSpace(q.Question) == "" {
+		return fmt.Errorf("question is empty")
+	}
+
+	switch q.Type {
+	case QuestionMultipleChoice, QuestionTrueFalse:
+		if err := validateOptions(q.Options); err != nil {
+			return err
+		}
+		if q.Type == QuestionTrueFalse && len(q.Options) != 2 {
+			return fmt.Errorf("true/false question has %d options", len(q.Options))
+		}
+		if q.Answer < 0 || q.Answer >= len(q.Options) {
+			return fmt.Errorf("answer %d is not one of the %d options", q.Answer, len(q.Options))
+		}
+	case QuestionMultiSelect:
+		if err := validateOptions(q.Options); err != nil {
+			return err
+		}
+		if len(q.Answers) == 0 {
+			return fmt.Errorf("multi-select question has no correct options")
+		}
+		for _, answer := range q.Answers {
+			if answer < 0 || answer >= len(q.Options) {
+				return fmt.Errorf("answer %d is not one of the %d options", answer, len(q.Options))
+			}
+		}
+	case QuestionCloze, QuestionShortAnswer, QuestionEssay:
+		if strings.TrimSpace(q.AnswerText) == "" {
+			return fmt.Errorf("%s question has no answer text", q.Type)
+		}
+		if q.Type == QuestionCloze && !blankPattern.MatchString(q.Question) {
+			return fmt.Errorf("cloze question has no blank")
+		}
+	default:
+		return fmt.Errorf("unknown question type %q", q.Type)
+	}
+	return nil
+}
+
+func validateOptions(options []string) error {
+	if len(options) < 2 {
+		return fmt.Errorf("question needs at least 2 options, has %d", len(options))
+	}
+
+	seen := make(map[string]bool, len(options))
+	for i, option := range options {
+		key := strings.ToLower(strings.TrimSpace(option))
+		if key == "" {
+			return fmt.Errorf("option %d is empty", i)
+		}
+		if seen[key] {
+			return fmt.Errorf("option %q appears more than once", option)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// normalizeQuestion fills in the question type and clears the fields that
+// don't apply to it, so every question is stored the same way. Multi-select
+// answers are sorted and de-duplicated, with Answer set to the first of them.
+func normalizeQuestion(q QuizData) QuizData {
+	if q.Type == "" {
+		q.Type = QuestionMultipleChoice
+	}
+
+	switch q.Type {
+	case QuestionMultipleChoice:
+		q.Answers, q.AnswerText, q.Rubric = nil, "", nil
+	case QuestionTrueFalse:
+		// Keep the meaning of the answer if the model listed false first
+		if len(q.Options) == 2 && strings.EqualFold(strings.TrimSpace(q.Options[0]), "false") {
+			q.Answer = 1 - q.Answer
+		}
+		q.Options = append([]string(nil), trueFalseOptions...)
+		q.Answers, q.AnswerText, q.Rubric = nil, "", nil
+	case QuestionMultiSelect:
+		seen := map[int]bool{}
+		answers := []int{}
+		for _, answer := range q.Answers {
+			if !seen[answer] {
+				seen[answer] = true
+				answers = append(answers, answer)
+			}
+		}
+		sort.Ints(answers)
+		q.Answers, q.AnswerText, q.Rubric = answers, "", nil
+		if len(answers) > 0 {
+			q.Answer = answers[0]
+		}
+	case QuestionCloze, QuestionShortAnswer, QuestionEssay:
+		q.Options, q.Answers, q.Answer = []string{}, nil, 0
+		q.AnswerText = strings.TrimSpace(q.AnswerText)
+		var rubric []string
+		for _, point := range q.Rubric {
+			if point = strings.TrimSpace(point); point != "" {
+				rubric = append(rubric, point)
+			}
+		}
+		q.Rubric = rubric
+		if q.Type == QuestionCloze {
+			q.Rubric = nil
+		}
+	}
+	return q
+}
+
+// ShuffleOptions puts the options in an order determined by seed, moving
+// the answers along with them
+func ShuffleOptions(q QuizData, seed int64) QuizData {
+	order := make([]int, len(q.Options))
+	for i := range order {
+		order[i] = i
+	}
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+
+	// position maps each option's old index to its new one
+	options := make([]string, len(order))
+	position := make([]int, len(order))
+	for i, from := range order {
+		options[i] = q.Options[from]
+		position[from] = i
+	}
+
+	q.Answer = position[q.Answer]
+	if len(q.Answers) > 0 {
+		answers := make([]int, len(q.Answers))
+		for i, answer := range q.Answers {
+			answers[i] = position[answer]
+		}
+		sort.Ints(answers)
+		q.Answers = answers
+		q.Answer = answers[0]
+	}
+	q.Options = options
+	q.Seed = seed
+	return q
+}
+
+// questionSeed derives a shuffle seed from the question itself, so the same
+// generated question is always laid out the same way
+func questionSeed(q QuizData) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(q.Question))
+	for _, option := range q.Options {
+		h.Write([]byte{0})
+		h.Write([]byte(option))
+	}
+	return int64(h.Sum64() &^ (1 << 63))
+}
+
+// prepareQuiz drops invalid questions, shuffles the options of the rest and
+// locates their sources in content. Models tend to copy the prompt example
+// and put the answer first. True/false options keep their order.
+func prepareQuiz(content string, quiz []QuizData) []QuizData {
+	prepared := make([]QuizData, 0, len(quiz))
+	for i, q := range quiz {
+		q = normalizeQuestion(q)
+		if err := ValidateQuestion(q); err != nil {
+			fmt.Printf("Dropping invalid quiz question %d: %v\n", i+1, err)
+			continue
+		}
+		if q.Type == QuestionMultipleChoice || q.Type == QuestionMultiSelect {
+			q = ShuffleOptions(q, questionSeed(q))
+		}
+		prepared = append(prepared, withQuizSource(content, q))
+	}
+	return prepared
+}
+
+// MatchesAnswer reports whether a written answer matches the expected one,
+// ignoring case, punctuation, extra whitespace and a leading article
+func MatchesAnswer(expected, given string) bool {
+	expected = normalizeAnswer(expected)
+	return expected != "" && expected == normalizeAnswer(given)
+}
+
+func normalizeAnswer(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 1 && (words[0] == "a" || words[0] == "an" || words[0] == "the") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// keyWordShare is the share of a key point's words an answer has to mention
+// to cover it
+const keyWordShare = 0.6
+
+// coversPoint reports whether an answer mentions most of the key words of a
+// point. It is a rough check that works without a model.
+func coversPoint(point, given string) bool {
+	if MatchesAnswer(point, given) {
+		return true
+	}
+	keywords := answerKeywords(point)
+	if len(keywords) == 0 {
+		return false
+	}
+	mentioned := answerKeywords(given)
+	found := 0
+	for word := range keywords {
+		if mentioned[word] {
+			found++
+		}
+	}
+	return float64(found)/float64(len(keywords)) >= keyWordShare
+}
+
+// answerKeywords returns the distinct words of text that carry meaning
+func answerKeywords(text string) map[string]bool {
+	keywords := map[string]bool{}
+	for _, word := range strings.Fields(normalizeAnswer(text)) {
+		if len(word) > 2 && !stopWords[word] {
+			keywords[word] = true
+		}
+	}
+	return keywords
+}
+
+// convertSimpleQuiz turns the multiple choice questions of the fallback quiz
+// into the requested types, taking turns through them. The fallback puts
+// the sentence each question is built from as the first option. Every
+// other true/false statement is negated, so the answer isn't always true.
+func convertSimpleQuiz(quiz []QuizData, types []string) []QuizData {
+	sentences := make([]string, len(quiz))
+	for i, q := range quiz {
+		sentences[i] = q.Options[q.Answer]
+	}
+
+	converted := make([]QuizData, 0, len(quiz))
+	statements := 0
+	for i, q := range quiz {
+		sentence := sentences[i]
+		switch types[i%len(types)] {
+		case QuestionTrueFalse:
+			q = QuizData{
+				Type:        QuestionTrueFalse,
+				Question:    sentence,
+				Options:     trueFalseOptions,
+				Answer:      0,
+				Explanation: sourceExplanation(sentence),
+				Source:      sentence,
+			}
+			if statements%2 == 1 {
+				q.Question, q.Answer = negateStatement(sentence), 1
+			}
+			statements++
+		case QuestionMultiSelect:
+			if multi, ok := multiSelectQuestion(sentences, i); ok {
+				q = multi
+			}
+		case QuestionCloze:
+			if term := clozeTerm(sentence); term != "" {
+				q = QuizData{
+					Type:        QuestionCloze,
+					Question:    blankOut(sentence, term),
+					AnswerText:  term,
+					Explanation: sourceExplanation(sentence),
+					Source:      sentence,
+				}
+			}
+		case QuestionShortAnswer, QuestionEssay:
+			q.Type = types[i%len(types)]
+			q.AnswerText = sentence
+		}
+		converted = append(converted, q)
+	}
+	return converted
+}
+
+// multiSelectQuestion asks which statements are true, with the i-th and
+// next sentences as the correct options and negations of the two after
+// them as the wrong ones. A single sentence can't make one, so the question
+// is left as multiple choice.
+func multiSelectQuestion(sentences []string, i int) (QuizData, bool) {
+	n := len(sentences)
+	if n < 2 {
+		return QuizData{}, false
+	}
+	first, second := sentences[i%n], sentences[(i+1)%n]
+	options := []string{first, second, negateStatement(sentences[(i+2)%n]), negateStatement(sentences[(i+3)%n])}
+	if validateOptions(options) != nil {
+		return QuizData{}, false
+	}
+	return QuizData{
+		Type:        QuestionMultiSelect,
+		Question:    "Which of these statements are true according to the text?",
+		Options:     options,
+		Answer:      0,
+		Answers:     []int{0, 1},
+		Explanation: fmt.Sprintf("The note says: \"%s\" and \"%s\"", first, second),
+		Source:      first,
+	}, true
+}
+
+// negatableVerbs are the verbs negateStatement puts "not" after
+var negatableVerbs = map[string]bool{
+	"is": true, "are": true, "was": true, "were": true,
+	"can": true, "could": true, "will": true, "would": true,
+	"should": true, "must": true, "may": true, "might": true,
+}
+
+// negateStatement turns a statement into its opposite by adding or removing
+// "not" after its first auxiliary verb, or failing that by denying it
+func negateStatement(sentence string) string {
+	words := strings.Fields(sentence)
+	for i, word := range words {
+		if strings.EqualFold(word, "cannot") {
+			words[i] = word[:3]
+			return strings.Join(words, " ")
+		}
+		if !negatableVerbs[strings.ToLower(word)] {
+			continue
+		}
+		if i+1 < len(words) && strings.EqualFold(words[i+1], "not") {
+			return strings.Join(append(words[:i+1], words[i+2:]...), " ")
+		}
+		negated := append(append(words[:i+1:i+1], "not"), words[i+1:]...)
+		return strings.Join(negated, " ")
+	}
+	return "It is not true that " + sentence
+}
+
+// clozeTerm picks the word to blank out of a sentence: the longest one,
+// which is most likely to be a key term
+func clozeTerm(sentence string) string {
+	term := ""
+	for _, word := range strings.Fields(sentence) {
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if len(word) > len(term) && !stopWords[strings.ToLower(word)] {
+			term = word
+		}
+	}
+	if len(term) < 5 {
+		return ""
+	}
+	return term
+}
+
+// blankOut replaces the first whole-word occurrence of term with a blank
+func blankOut(sentence, term string) string {
+	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(term) + `\b`)
+	done := false
+	return pattern.ReplaceAllStringFunc(sentence, func(match string) string {
+		if done {
+			return match
+		}
+		done = true
+		return "_____"
+	})
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNegateStatement(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Chlorophyll is a pigment.", "Chlorophyll is not a pigment."},
+		{"Plants can store glucose as starch.", "Plants can not store glucose as starch."},
+		{"Roots are not green.", "Roots are green."},
+		{"Animals cannot photosynthesise.", "Animals can photosynthesise."},
+		{"Light  Is needed.", "Light Is not needed."},
+		{"Plants store glucose as starch.", "It is not true that Plants store glucose as starch."},
+	}
+	for _, tt := range tests {
+		if got := negateStatement(tt.in); got != tt.want {
+			t.Errorf("negateStatement(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackTrueFalse(t *testing.T) {
+	useProvider(t, stubProvider{err: errors.New("unreachable")})
+
+	quiz, err := GenerateQuiz(context.Background(), testNote, QuizOptions{Types: []string{QuestionTrueFalse}})
+	if err != nil {
+		t.Fatalf("GenerateQuiz: %v", err)
+	}
+	if len(quiz) < 2 {
+		t.Fatalf("got %d questions, want several", len(quiz))
+	}
+	answers := map[int]int{}
+	for _, q := range quiz {
+		answers[q.Answer]++
+		if q.Answer == 1 && q.Question == q.Source {
+			t.Errorf("false statement %q is the source sentence", q.Question)
+		}
+		if q.Answer == 0 && q.Question != q.Source {
+			t.Errorf("true statement %q differs from the source %q", q.Question, q.Source)
+		}
+	}
+	if diff := answers[0] - answers[1]; diff < 0 || diff > 1 {
+		t.Errorf("got %d true and %d false statements, want about half of each", answers[0], answers[1])
+	}
+}
+
+func TestFallbackMultiSelect(t *testing.T) {
+	useProvider(t, stubProvider{err: errors.New("unreachable")})
+
+	quiz, err := GenerateQuiz(context.Background(), testNote, QuizOptions{Types: []string{QuestionMultiSelect}})
+	if err != nil {
+		t.Fatalf("GenerateQuiz: %v", err)
+	}
+	if len(quiz) == 0 {
+		t.Fatal("got no questions")
+	}
+	for _, q := range quiz {
+		if q.Type != QuestionMultiSelect {
+			t.Errorf("question %q has type %q", q.Question, q.Type)
+			continue
+		}
+		if len(q.Answers) != 2 || len(q.Options) != 4 {
+			t.Errorf("question %q has answers %v of %d options, want 2 of 4", q.Question, q.Answers, len(q.Options))
+			continue
+		}
+		for _, answer := range q.Answers {
+			if !strings.Contains(testNote, q.Options[answer]) {
+				t.Errorf("correct option %q is not from the note", q.Options[answer])
+			}
+		}
+	}
+}
+
+func TestConvertSimpleQuizSingleSentence(t *testing.T) {
+	quiz := []QuizData{{Question: "What is this?", Options: []string{"Leaves hold chlorophyll", "Roots", "Stems", "Seeds"}}}
+	converted := convertSimpleQuiz(quiz, []string{QuestionMultiSelect})
+	if converted[0].Type != "" || len(converted[0].Answers) != 0 {
+		t.Errorf("one sentence was turned into %+v, want the multiple choice question kept", converted[0])
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minQuoteLength keeps very short quotes, which could match almost
+// anywhere, from being pinned to the wrong passage
+const minQuoteLength = 12
+
+// SourceSpan is the passage a flashcard or question was drawn from, as byte
+// offsets into the note content
+type SourceSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// LocateQuote finds quote in content, ignoring case and differences in
+// whitespace, since models rarely copy text exactly
+func LocateQuote(content, quote string) (SourceSpan, bool) {
+	quote = strings.TrimSpace(quote)
+	if len(quote) < minQuoteLength {
+		return SourceSpan{}, false
+	}
+	if i := strings.Index(content, quote); i >= 0 {
+		return SourceSpan{Start: i, End: i + len(quote)}, true
+	}
+
+	normalized, starts, ends := normalizeForSearch(content)
+	needle, _, _ := normalizeForSearch(quote)
+	i := strings.Index(normalized, needle)
+	if i < 0 || needle == "" {
+		return SourceSpan{}, false
+	}
+	return SourceSpan{Start: starts[i], End: ends[i+len(needle)-1]}, true
+}
+
+// normalizeForSearch lowercases text, drops leading and trailing whitespace
+// and collapses whitespace runs to a single space. starts and ends map each
+// byte of the result back to the rune it came from in text.
+func normalizeForSearch(text string) (normalized string, starts, ends []int) {
+	var b strings.Builder
+	space := false
+	for i, r := range text {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			if space || b.Len() == 0 {
+				continue
+			}
+			space = true
+			r = ' '
+		} else {
+			space = false
+		}
+
+		lower := strings.ToLower(string(r))
+		b.WriteString(lower)
+		for range len(lower) {
+			starts = append(starts, i)
+			ends = append(ends, i+size)
+		}
+	}
+	return strings.TrimSuffix(b.String(), " "), starts, ends
+}
+
+// withFlashcardSource sets the span of the passage a card quotes
+func withFlashcardSource(content string, card FlashcardData) FlashcardData {
+	if span, ok := LocateQuote(content, card.Source); ok {
+		card.Span = &span
+	}
+	return card
+}
+
+// withQuizSource sets the span of the passage a question quotes
+func withQuizSource(content string, q QuizData) QuizData {
+	if span, ok := LocateQuote(content, q.Source); ok {
+		q.Span = &span
+	}
+	return q
+}
+
+// withTermSource sets the span of the passage a glossary term quotes
+func withTermSource(content string, term TermData) TermData {
+	if span, ok := LocateQuote(content, term.Source); ok {
+		term.Span = &span
+	}
+	return term
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenFunc receives generated text as it streams in. Returning an error
+// stops the generation.
+type TokenFunc func(token string) error
+
+// StreamingProvider is implemented by providers that can report tokens as
+// they are generated. Stream returns the full text once generation ends.
+type StreamingProvider interface {
+	Stream(ctx context.Context, prompt string, opts CompletionOptions, onToken TokenFunc) (string, error)
+}
+
+var (
+	timeoutMu         sync.RWMutex
+	generationTimeout = 2 * time.Minute
+)
+
+// SetGenerationTimeout bounds every individual provider call
+func SetGenerationTimeout(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timeoutMu.Lock()
+	defer timeoutMu.Unlock()
+	generationTimeout = d
+}
+
+func currentGenerationTimeout() time.Duration {
+	timeoutMu.RLock()
+	defer timeoutMu.RUnlock()
+	return generationTimeout
+}
+
+// complete runs one provider call under the generation timeout, streaming
+// through onToken when both a callback and a streaming provider are
+// available. Callers are responsible for emitting text that wasn't streamed.
+func complete(ctx context.Context, provider Provider, prompt string, opts CompletionOptions, onToken TokenFunc) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, currentGenerationTimeout())
+	defer cancel()
+
+	if onToken != nil {
+		if sp, ok := provider.(StreamingProvider); ok {
+			return sp.Stream(ctx, prompt, opts, onToken)
+		}
+	}
+	return provider.Complete(ctx, prompt, opts)
+}
+
+// tokenStream wraps a TokenFunc and remembers whether anything was sent, so
+// fallbacks know if the client has already seen partial output
+type tokenStream struct {
+	onToken TokenFunc
+	started bool
+}
+
+func newTokenStream(onToken TokenFunc) *tokenStream {
+	return &tokenStream{onToken: onToken}
+}
+
+// callback returns the TokenFunc to hand to complete, or nil when the
+// caller isn't streaming
+func (s *tokenStream) callback() TokenFunc {
+	if s.onToken == nil {
+		return nil
+	}
+	return s.emit
+}
+
+func (s *tokenStream) emit(token string) error {
+	if s.onToken == nil || token == "" {
+		return nil
+	}
+	s.started = true
+	return s.onToken(token)
+}
+
+// objectScanner picks complete list items out of a streamed JSON
+// document, so they can be handed out before the list is finished. An item
+// is an object directly inside an array and not inside another item.
+type objectScanner struct {
+	buf      strings.Builder
+	frames   []scanFrame
+	items    int // Open frames that are items
+	inString bool
+	escaped  bool
+}
+
+type scanFrame struct {
+	open  rune
+	start int
+	item  bool
+}
+
+// write consumes the next piece of the document and returns any items it
+// completed
+func (s *objectScanner) write(token string) []json.RawMessage {
+	var objects []json.RawMessage
+	for _, r := range token {
+		pos := s.buf.Len()
+		s.buf.WriteRune(r)
+
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case r == '\\':
+				s.escaped = true
+			case r == '"':
+				s.inString = false
+			}
+			continue
+		}
+
+		switch r {
+		case '"':
+			s.inString = true
+		case '[', '{':
+			item := r == '{' && s.items == 0 && len(s.frames) > 0 && s.frames[len(s.frames)-1].open == '['
+			if item {
+				s.items++
+			}
+			s.frames = append(s.frames, scanFrame{open: r, start: pos, item: item})
+		case ']', '}':
+			if len(s.frames) == 0 {
+				continue
+			}
+			frame := s.frames[len(s.frames)-1]
+			s.frames = s.frames[:len(s.frames)-1]
+			if frame.item {
+				s.items--
+				objects = append(objects, json.RawMessage(s.buf.String()[frame.start:]))
+			}
+		}
+	}
+	return objects
+}
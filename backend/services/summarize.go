@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SummarizationOptions controls how long documents are split for
+// map-reduce summarization. All sizes are in (estimated) tokens.
+type SummarizationOptions struct {
+	ChunkTokens   int // Size of each section summarized in the map step
+	OverlapTokens int // Tokens shared between consecutive sections
+	ContextTokens int // Largest input sent to the model in a single prompt
+	SectionTokens int // Upper bound on each section summary
+}
+
+// DefaultSummarizationOptions suits models with a 4k token context
+func DefaultSummarizationOptions() SummarizationOptions {
+	return SummarizationOptions{
+		ChunkTokens:   1000,
+		OverlapTokens: 100,
+		ContextTokens: 3000,
+		SectionTokens: 300,
+	}
+}
+
+var (
+	summarizationMu   sync.RWMutex
+	summarizationOpts = DefaultSummarizationOptions()
+)
+
+// SetSummarizationOptions replaces the options used by GenerateSummary,
+// keeping defaults for any non-positive fields
+func SetSummarizationOptions(opts SummarizationOptions) {
+	defaults := DefaultSummarizationOptions()
+	if opts.ChunkTokens <= 0 {
+		opts.ChunkTokens = defaults.ChunkTokens
+	}
+	if opts.OverlapTokens < 0 || opts.OverlapTokens >= opts.ChunkTokens {
+		opts.OverlapTokens = 0
+	}
+	if opts.ContextTokens <= 0 {
+		opts.ContextTokens = defaults.ContextTokens
+	}
+	if opts.SectionTokens <= 0 {
+		opts.SectionTokens = defaults.SectionTokens
+	}
+
+	summarizationMu.Lock()
+	defer summarizationMu.Unlock()
+	summarizationOpts = opts
+}
+
+func currentSummarizationOptions() SummarizationOptions {
+	summarizationMu.RLock()
+	defer summarizationMu.RUnlock()
+	return summarizationOpts
+}
+
+// summarizeLong runs the map step over each section of content and then
+// combines the section summaries into one in the requested style, streaming
+// only the final step
+func summarizeLong(ctx context.Context, content string, opts SummarizationOptions, style SummaryOptions, stream *tokenStream) (string, error) {
+	chunks := ChunkText(content, ChunkOptions{
+		Size:    opts.ChunkTokens * charsPerToken,
+		Overlap: opts.OverlapTokens * charsPerToken,
+	})
+
+	// Budget each section so the combined summaries fit in one prompt
+	sectionTokens := min(opts.SectionTokens, opts.ContextTokens/max(len(chunks), 1))
+	if sectionTokens < 64 {
+		sectionTokens = 64
+	}
+
+	fmt.Printf("Summarizing %d sections of %d estimated tokens\n", len(chunks), EstimateTokens(content))
+
+	partials := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		partials = append(partials, summarizeSection(ctx, chunk, len(chunks), sectionTokens))
+	}
+
+	return combineSummaries(ctx, partials, opts, style, stream)
+}
+
+// summarizeSection produces the map-step summary for one chunk, falling
+// back to the extractive summary if the provider fails
+func summarizeSection(ctx context.Context, chunk Chunk, total, maxTokens int) string {
+	prompt := fmt.Sprintf(`The following is section %d of %d of a longer document. Summarize the key points, definitions and arguments in this section in at most %d words. Do not add an introduction or mention that this is a section.
+
+%s
+
+Section summary:`, chunk.Index+1, total, maxTokens*3/4, quoteNotes(chunk.Text))
+
+	provider := CurrentProvider()
+	summary, err := complete(ctx, provider, prompt, CompletionOptions{
+		Task:      TaskSummary,
+		MaxTokens: maxTokens,
+	}, nil)
+	summary = strings.TrimSpace(summary)
+	if err != nil || summary == "" {
+		fmt.Printf("AI provider %s failed for section %d: %v\n", provider.Name(), chunk.Index+1, err)
+		return createSimpleSummary(chunk.Text)
+	}
+
+	return summary
+}
+
+// combineSummaries is the reduce step. When the section summaries are too
+// large for one prompt they are combined in batches first.
+func combineSummaries(ctx context.Context, partials []string, opts SummarizationOptions, style SummaryOptions, stream *tokenStream) (string, error) {
+	if len(partials) == 1 {
+		return styleSimpleSummary(partials[0], style.Style), nil
+	}
+
+	joined := strings.Join(partials, "\n\n")
+	if EstimateTokens(joined) > opts.ContextTokens && len(partials) > 2 {
+		var batches []string
+		var batch []string
+		batchTokens := 0
+		for _, partial := range partials {
+			tokens := EstimateTokens(partial)
+			if len(batch) > 0 && batchTokens+tokens > opts.ContextTokens {
+				batches = append(batches, combineBatch(ctx, batch, opts.SectionTokens))
+				batch, batchTokens = nil, 0
+			}
+			batch = append(batch, partial)
+			batchTokens += tokens
+		}
+		if len(batch) > 0 {
+			batches = append(batches, combineBatch(ctx, batch, opts.SectionTokens))
+		}
+
+		// Only recurse if batching actually shrank the input
+		if len(batches) < len(partials) {
+			return combineSummaries(ctx, batches, opts, style, stream)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	prompt := fmt.Sprintf(`Below are summaries of consecutive sections of one document. Combine them into one summary of the whole document, written as %s, in at most %d words. Remove repetition and keep the original order of ideas.
+
+%s
+
+Summary:`, style.format(), style.words(), quoteNotes(joined))
+
+	provider := CurrentProvider()
+	summary, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskSummary}, stream.callback())
+	summary = strings.TrimSpace(summary)
+	if stream.started {
+		// Partial output has been sent, so there's nothing to fall back to
+		return summary, err
+	}
+	if err != nil || len(summary) < 50 {
+		fmt.Printf("AI provider %s failed to combine section summaries: %v\n", provider.Name(), err)
+		return styleSimpleSummary(strings.Join(partials, " "), style.Style), nil
+	}
+
+	return summary, nil
+}
+
+// combineBatch merges a batch of section summaries into one intermediate
+// summary, concatenating them if the provider fails
+func combineBatch(ctx context.Context, batch []string, maxTokens int) string {
+	joined := strings.Join(batch, "\n\n")
+	if len(batch) == 1 || ctx.Err() != nil {
+		return joined
+	}
+
+	prompt := fmt.Sprintf(`Merge the following section summaries into one summary of at most %d words, keeping every key point:
+
+%s
+
+Merged summary:`, maxTokens*3/4, quoteNotes(joined))
+
+	summary, err := complete(ctx, CurrentProvider(), prompt, CompletionOptions{
+		Task:      TaskSummary,
+		MaxTokens: maxTokens,
+	}, nil)
+	summary = strings.TrimSpace(summary)
+	if err != nil || summary == "" {
+		return joined
+	}
+
+	return summary
+}
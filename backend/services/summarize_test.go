@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCombineSummariesStylesEveryPath(t *testing.T) {
+	useProvider(t, stubProvider{err: errors.New("unreachable")})
+
+	first := "Photosynthesis turns light into chemical energy. It takes place in the chloroplasts."
+	second := "The Calvin cycle fixes carbon dioxide. Plants store the glucose as starch."
+	tests := []struct {
+		name     string
+		partials []string
+	}{
+		{"single section", []string{first}},
+		{"provider failure", []string{first, second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := combineSummaries(context.Background(), tt.partials, DefaultSummarizationOptions(),
+				SummaryOptions{Style: SummaryBullets}, newTokenStream(nil))
+			if err != nil {
+				t.Fatalf("combineSummaries: %v", err)
+			}
+			lines := strings.Split(summary, "\n")
+			if len(lines) != 2*len(tt.partials) {
+				t.Errorf("got %d bullets, want %d:\n%s", len(lines), 2*len(tt.partials), summary)
+			}
+			for _, line := range lines {
+				if !strings.HasPrefix(line, "- ") {
+					t.Errorf("line %q is not a bullet", line)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateSummaryOfLongNote(t *testing.T) {
+	SetSummarizationOptions(SummarizationOptions{ChunkTokens: 80, ContextTokens: 100, SectionTokens: 40})
+	t.Cleanup(func() { SetSummarizationOptions(DefaultSummarizationOptions()) })
+	useProvider(t, stubProvider{err: errors.New("unreachable")})
+
+	long := strings.Repeat(testNote+"\n\n", 4)
+	summary, err := GenerateSummary(context.Background(), long, SummaryOptions{Style: SummaryOutline})
+	if err != nil {
+		t.Fatalf("GenerateSummary: %v", err)
+	}
+	if !strings.HasPrefix(summary, "1. ") || !strings.Contains(summary, "\n2. ") {
+		t.Errorf("summary is not an outline:\n%s", summary)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Tutor modes control how the tutor responds to the student
+const (
+	TutorModeExplain  = "explain"  // Explain concepts directly
+	TutorModeSocratic = "socratic" // Guide with questions instead of answers
+	TutorModeQuiz     = "quiz"     // Ask questions and check the student's answers
+)
+
+// tutorHistoryTokens bounds how much of the conversation is replayed
+const tutorHistoryTokens = 1500
+
+var tutorInstructions = map[string]string{
+	TutorModeExplain:  `You are a patient study tutor. Explain the answer to the student's latest message clearly and accurately, building on the conversation so far. Ground your explanation in the numbered passages from their notes and cite them like [1].`,
+	TutorModeSocratic: `You are a Socratic study tutor. Do not give the answer away. Help the student reason their way to it by asking one short, probing question at a time, based on the numbered passages from their notes. If they reach the right idea, confirm it and cite the passage like [1].`,
+	TutorModeQuiz:     `You are a study tutor quizzing the student on their notes. If the student's latest message answers your previous question, tell them whether it is correct and briefly why, citing the numbered passages like [1]. Then ask exactly one new question about the passages.`,
+}
+
+// TutorMessage is one turn of a tutor conversation
+type TutorMessage struct {
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// ValidTutorMode reports whether mode is a supported tutor mode
+func ValidTutorMode(mode string) bool {
+	_, ok := tutorInstructions[mode]
+	return ok
+}
+
+// TutorReply generates the tutor's next message in a conversation,
+// grounded in passages from the note
+func TutorReply(ctx context.Context, mode string, history []TutorMessage, message string, passages []Passage) (Answer, error) {
+	return StreamTutorReply(ctx, mode, history, message, passages, nil)
+}
+
+// StreamTutorReply generates a reply like TutorReply, passing the text to
+// onToken as it is generated. onToken may be nil.
+func StreamTutorReply(ctx context.Context, mode string, history []TutorMessage, message string, passages []Passage, onToken TokenFunc) (Answer, error) {
+	if !ValidTutorMode(mode) {
+		return Answer{}, fmt.Errorf("unknown tutor mode %q", mode)
+	}
+	if strings.TrimSpace(message) == "" {
+		return Answer{}, fmt.Errorf("message cannot be empty")
+	}
+
+	excerpts := formatPassages(passages)
+	prompt := buildTutorPrompt(mode, history, message, excerpts)
+	stream := newTokenStream(onToken)
+
+	provider := CurrentProvider()
+	response, err := complete(ctx, provider, prompt, CompletionOptions{Task: TaskTutor}, stream.callback())
+	response = strings.TrimSpace(response)
+	if stream.started && err != nil {
+		return Answer{}, err
+	}
+	if err != nil || response == "" {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Answer{}, ctxErr
+		}
+		fmt.Printf("AI provider %s failed for tutor: %v\n", provider.Name(), err)
+		reply := createSimpleTutorReply(mode, message, passages)
+		return reply, stream.emit(reply.Answer)
+	}
+
+	if !stream.started {
+		if err := stream.emit(response); err != nil {
+			return Answer{}, err
+		}
+	}
+	return Answer{Answer: response, Citations: citedPassages(response, passages)}, nil
+}
+
+// buildTutorPrompt lays out the instructions, note passages and as much
+// recent history as fits the budget
+func buildTutorPrompt(mode string, history []TutorMessage, message, excerpts string) string {
+	// Walk back from the latest turn until the budget is spent
+	start := len(history)
+	budget := tutorHistoryTokens
+	for start > 0 {
+		tokens := EstimateTokens(history[start-1].Content)
+		if tokens > budget {
+			break
+		}
+		budget -= tokens
+		start--
+	}
+
+	var transcript strings.Builder
+	for _, turn := range history[start:] {
+		speaker := "Student"
+		if turn.Role == "assistant" {
+			speaker = "Tutor"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", speaker, turn.Content)
+	}
+	fmt.Fprintf(&transcript, "Student: %s\n\nTutor:", message)
+
+	if excerpts == "" {
+		excerpts = "(No relevant passages were found in the notes.)"
+	}
+
+	return fmt.Sprintf(`%s
+
+Passages from the student's notes:
+%s
+
+Conversation:
+%s`, tutorInstructions[mode], quoteNotes(excerpts), transcript.String())
+}
+
+// createSimpleTutorReply responds without AI using the same heuristics as
+// the other fallbacks
+func createSimpleTutorReply(mode, message string, passages []Passage) Answer {
+	if len(passages) == 0 {
+		return Answer{
+			Answer:    "I couldn't find anything in your notes about that. Could you rephrase the question?",
+			Citations: []Passage{},
+		}
+	}
+
+	switch mode {
+	case TutorModeSocratic:
+		answer := createSimpleAnswer(message, passages)
+		return Answer{
+			Answer:    "What do you think the notes mean when they say: \"" + firstSentence(answer.Citations[0].Text) + "\"?",
+			Citations: answer.Citations[:1],
+		}
+	case TutorModeQuiz:
+		flashcards := createSimpleFlashcards(passages[0].Text)
+		return Answer{
+			Answer:    "Here's a question for you: " + flashcards[0].Question,
+			Citations: passages[:1],
+		}
+	default:
+		return createSimpleAnswer(message, passages)
+	}
+}
+
+// firstSentence returns text up to its first sentence terminator
+func firstSentence(text string) string {
+	if i := strings.IndexAny(text, ".!?"); i != -1 {
+		return strings.TrimSpace(text[:i+1])
+	}
+	return strings.TrimSpace(text)
+}
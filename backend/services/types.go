@@ -1,14 +1,54 @@
 package services
 
+import "fmt"
+
 // FlashcardData represents a flashcard structure
 type FlashcardData struct {
-	Question string `json:"question"`
-	Answer   string `json:"answer"`
+	Question    string      `json:"question"`
+	Answer      string      `json:"answer"`
+	Explanation string      `json:"explanation,omitempty"` // Why the answer is right
+	Source      string      `json:"source,omitempty"`      // Passage of the note the card was drawn from
+	Span        *SourceSpan `json:"span,omitempty"`        // Where Source is in the note, if it could be found
 }
 
 // QuizData represents a quiz question structure
 type QuizData struct {
-	Question string   `json:"question"`
-	Options  []string `json:"options"`
-	Answer   int      `json:"answer"` // Index of correct option
+	Type        string      `json:"type"` // One of QuestionTypes; empty is read as multiple choice
+	Question    string      `json:"question"`
+	Options     []string    `json:"options"`
+	Answer      int         `json:"answer"`                // Index of correct option
+	Answers     []int       `json:"answers,omitempty"`     // Indexes of every correct option, for multi-select
+	AnswerText  string      `json:"answer_text,omitempty"` // Expected or model answer, for cloze, short answer and essay
+	Rubric      []string    `json:"rubric,omitempty"`      // Key points a full answer covers, for short answer and essay
+	Seed        int64       `json:"seed,omitempty"`        // Seed the options were shuffled with
+	Explanation string      `json:"explanation,omitempty"` // Why the answer is right
+	Source      string      `json:"source,omitempty"`      // Passage of the note the question was drawn from
+	Span        *SourceSpan `json:"span,omitempty"`        // Where Source is in the note, if it could be found
+}
+
+// TermData is a key term of a note with its definition
+type TermData struct {
+	Term       string      `json:"term"`
+	Definition string      `json:"definition"`
+	Source     string      `json:"source,omitempty"` // Passage of the note that defines or explains the term
+	Span       *SourceSpan `json:"span,omitempty"`   // Where Source is in the note, if it could be found
+}
+
+// QuizOptions controls quiz generation
+type QuizOptions struct {
+	GenerationOptions
+	Types []string `json:"types,omitempty"` // Question types to mix; multiple choice when empty
+}
+
+// Validate checks the generation options and question types
+func (o QuizOptions) Validate() error {
+	if err := o.GenerationOptions.Validate(); err != nil {
+		return err
+	}
+	for _, t := range o.Types {
+		if !IsQuestionType(t) {
+			return fmt.Errorf("unknown question type %q", t)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,123 @@
+// Package srs schedules flashcard reviews with the SM-2 spaced repetition
+// algorithm, using the four answer buttons popularised by Anki instead of
+// SM-2's 0-5 quality scale. It has no dependencies so it can be tested and
+// tuned in isolation.
+package srs
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Grade is how well the student recalled a card
+type Grade int
+
+const (
+	Again Grade = iota // Forgot the answer
+	Hard               // Recalled with serious difficulty
+	Good               // Recalled after some hesitation
+	Easy               // Recalled instantly
+)
+
+// Scheduling parameters
+const (
+	InitialEase = 2.5
+	MinimumEase = 1.3
+
+	// RelearnDelay is how soon a forgotten card comes back, so it can be
+	// retried in the same session
+	RelearnDelay = 10 * time.Minute
+
+	graduatingInterval = 1 // Days until the first review after learning a card
+	secondInterval     = 6 // Days until the second review
+	easyInterval       = 4 // Days until the first review of a card marked easy
+	hardFactor         = 1.2
+	easyBonus          = 1.3
+)
+
+var gradeNames = []string{"again", "hard", "good", "easy"}
+
+func (g Grade) String() string {
+	if g < Again || g > Easy {
+		return fmt.Sprintf("Grade(%d)", int(g))
+	}
+	return gradeNames[g]
+}
+
+// ParseGrade converts "again", "hard", "good" or "easy" to a Grade
+func ParseGrade(s string) (Grade, error) {
+	for i, name := range gradeNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Grade(i), nil
+		}
+	}
+	return 0, fmt.Errorf("grade must be one of again, hard, good or easy, got %q", s)
+}
+
+// State is the review history of one card for one student
+type State struct {
+	Ease         float64    // Multiplier applied to the interval on success
+	Interval     int        // Days between the last review and Due
+	Repetitions  int        // Successful reviews in a row
+	Lapses       int        // Times the card was forgotten after being learned
+	Due          time.Time  // When the card should next be reviewed
+	LastReviewed *time.Time // Nil for a card that has never been reviewed
+}
+
+// NewState returns the state of a card that has never been reviewed. It is
+// due immediately.
+func NewState(now time.Time) State {
+	return State{Ease: InitialEase, Due: now}
+}
+
+// Review returns the state after reviewing a card at time now
+func Review(s State, grade Grade, now time.Time) State {
+	if s.Ease < MinimumEase {
+		s.Ease = InitialEase
+	}
+	reviewed := now
+	s.LastReviewed = &reviewed
+
+	if grade == Again {
+		if s.Repetitions > 0 {
+			s.Lapses++
+		}
+		s.Repetitions = 0
+		s.Interval = 0
+		s.Ease = math.Max(MinimumEase, s.Ease-0.2)
+		s.Due = now.Add(RelearnDelay)
+		return s
+	}
+
+	switch s.Repetitions {
+	case 0:
+		s.Interval = graduatingInterval
+		if grade == Easy {
+			s.Interval = easyInterval
+		}
+	case 1:
+		s.Interval = secondInterval
+		if grade == Hard {
+			s.Interval = graduatingInterval + 1
+		}
+	default:
+		factor := s.Ease
+		switch grade {
+		case Hard:
+			factor = hardFactor
+		case Easy:
+			factor = s.Ease * easyBonus
+		}
+		// Always move the card at least one day further out
+		s.Interval = max(s.Interval+1, int(math.Round(float64(s.Interval)*factor)))
+	}
+
+	// SM-2 ease update, with Hard, Good and Easy standing for qualities 3-5
+	q := float64(grade) + 2
+	s.Ease = math.Max(MinimumEase, s.Ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+	s.Repetitions++
+	s.Due = now.AddDate(0, 0, s.Interval)
+	return s
+}
@@ -0,0 +1,116 @@
+package srs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReview(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	learning := State{Ease: InitialEase, Interval: 1, Repetitions: 1, Due: now}
+	mature := State{Ease: InitialEase, Interval: 10, Repetitions: 3, Due: now}
+
+	tests := []struct {
+		name        string
+		state       State
+		grade       Grade
+		interval    int
+		ease        float64
+		repetitions int
+		lapses      int
+		due         time.Time
+	}{
+		{"new again", NewState(now), Again, 0, 2.3, 0, 0, now.Add(RelearnDelay)},
+		{"new hard", NewState(now), Hard, 1, 2.36, 1, 0, now.AddDate(0, 0, 1)},
+		{"new good", NewState(now), Good, 1, 2.5, 1, 0, now.AddDate(0, 0, 1)},
+		{"new easy", NewState(now), Easy, 4, 2.6, 1, 0, now.AddDate(0, 0, 4)},
+
+		{"learning again", learning, Again, 0, 2.3, 0, 1, now.Add(RelearnDelay)},
+		{"learning hard", learning, Hard, 2, 2.36, 2, 0, now.AddDate(0, 0, 2)},
+		{"learning good", learning, Good, 6, 2.5, 2, 0, now.AddDate(0, 0, 6)},
+		{"learning easy", learning, Easy, 6, 2.6, 2, 0, now.AddDate(0, 0, 6)},
+
+		{"mature again", mature, Again, 0, 2.3, 0, 1, now.Add(RelearnDelay)},
+		{"mature hard", mature, Hard, 12, 2.36, 4, 0, now.AddDate(0, 0, 12)},
+		{"mature good", mature, Good, 25, 2.5, 4, 0, now.AddDate(0, 0, 25)},
+		{"mature easy", mature, Easy, 33, 2.6, 4, 0, now.AddDate(0, 0, 33)},
+
+		{"minimum ease hard", State{Ease: MinimumEase, Interval: 1, Repetitions: 2}, Hard, 2, MinimumEase, 3, 0, now.AddDate(0, 0, 2)},
+		{"minimum ease again", State{Ease: MinimumEase, Interval: 5, Repetitions: 2, Lapses: 2}, Again, 0, MinimumEase, 0, 3, now.Add(RelearnDelay)},
+		{"unset ease", State{}, Good, 1, 2.5, 1, 0, now.AddDate(0, 0, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Review(tt.state, tt.grade, now)
+			if got.Interval != tt.interval {
+				t.Errorf("interval = %d, want %d", got.Interval, tt.interval)
+			}
+			if math.Abs(got.Ease-tt.ease) > 1e-9 {
+				t.Errorf("ease = %v, want %v", got.Ease, tt.ease)
+			}
+			if got.Repetitions != tt.repetitions {
+				t.Errorf("repetitions = %d, want %d", got.Repetitions, tt.repetitions)
+			}
+			if got.Lapses != tt.lapses {
+				t.Errorf("lapses = %d, want %d", got.Lapses, tt.lapses)
+			}
+			if !got.Due.Equal(tt.due) {
+				t.Errorf("due = %v, want %v", got.Due, tt.due)
+			}
+			if got.LastReviewed == nil || !got.LastReviewed.Equal(now) {
+				t.Errorf("last reviewed = %v, want %v", got.LastReviewed, now)
+			}
+		})
+	}
+}
+
+func TestReviewAlwaysMovesForward(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, grade := range []Grade{Hard, Good, Easy} {
+		state := NewState(now)
+		for i := 0; i < 10; i++ {
+			previous := state.Interval
+			state = Review(state, grade, now)
+			if state.Interval <= previous {
+				t.Fatalf("%s review %d: interval went from %d to %d", grade, i+1, previous, state.Interval)
+			}
+		}
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Grade
+		wantErr bool
+	}{
+		{"again", Again, false},
+		{"Hard", Hard, false},
+		{" good ", Good, false},
+		{"EASY", Easy, false},
+		{"perfect", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGrade(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseGrade(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseGrade(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGradeString(t *testing.T) {
+	for grade := Again; grade <= Easy; grade++ {
+		if parsed, err := ParseGrade(grade.String()); err != nil || parsed != grade {
+			t.Errorf("%v does not round-trip: %v, %v", grade, parsed, err)
+		}
+	}
+	if s := Grade(7).String(); s != "Grade(7)" {
+		t.Errorf("Grade(7).String() = %q", s)
+	}
+}
@@ -43,6 +43,17 @@ export interface Quiz {
   created_at: string;
 }
 
+export interface Job<T = unknown> {
+  id: number;
+  note_id: number;
+  type: string;
+  status: "queued" | "running" | "succeeded" | "failed";
+  progress: number;
+  result?: T;
+  error?: string;
+  created_at: string;
+}
+
 export interface StudySession {
   id: number;
   user_id: number;
@@ -8,6 +8,7 @@ import {
   Summary,
   Flashcard,
   Quiz,
+  Job,
   StudySession,
   SearchRequest,
   SearchResult,
@@ -15,6 +16,9 @@ import {
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
 
+// How often a queued generation job is checked on
+const JOB_POLL_INTERVAL_MS = 1000;
+
 class ApiClient {
   private baseURL: string;
   private token: string | null = null;
@@ -55,6 +59,19 @@ class ApiClient {
     return response.json();
   }
 
+  // Generation endpoints queue a job; wait for it and return what it saved
+  private async waitForJob<T>(endpoint: string): Promise<T> {
+    let job = await this.request<Job<T>>(endpoint, { method: "POST" });
+    while (job.status === "queued" || job.status === "running") {
+      await new Promise((resolve) => setTimeout(resolve, JOB_POLL_INTERVAL_MS));
+      job = await this.request<Job<T>>(`/api/jobs/${job.id}`);
+    }
+    if (job.status === "failed") {
+      throw new Error(job.error || "Generation failed");
+    }
+    return job.result as T;
+  }
+
   setToken(token: string) {
     this.token = token;
     if (typeof window !== "undefined") {
@@ -123,9 +140,7 @@ class ApiClient {
   }
 
   async generateSummary(noteId: number): Promise<Summary> {
-    return this.request<Summary>(`/api/study/notes/${noteId}/summary`, {
-      method: "POST",
-    });
+    return this.waitForJob<Summary>(`/api/study/notes/${noteId}/summary`);
   }
 
   async getFlashcards(noteId: number): Promise<Flashcard[]> {
@@ -133,9 +148,7 @@ class ApiClient {
   }
 
   async generateFlashcards(noteId: number): Promise<Flashcard[]> {
-    return this.request<Flashcard[]>(`/api/study/notes/${noteId}/flashcards`, {
-      method: "POST",
-    });
+    return this.waitForJob<Flashcard[]>(`/api/study/notes/${noteId}/flashcards`);
   }
 
   async getQuiz(noteId: number): Promise<Quiz[]> {
@@ -143,9 +156,7 @@ class ApiClient {
   }
 
   async generateQuiz(noteId: number): Promise<Quiz[]> {
-    return this.request<Quiz[]>(`/api/study/notes/${noteId}/quiz`, {
-      method: "POST",
-    });
+    return this.waitForJob<Quiz[]>(`/api/study/notes/${noteId}/quiz`);
   }
 
   async createStudySession(data: {